@@ -10,8 +10,10 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/atroxxxxxx/embed-store/internal/admin"
 	"github.com/atroxxxxxx/embed-store/internal/cluster"
 	database "github.com/atroxxxxxx/embed-store/internal/db"
+	"github.com/atroxxxxxx/embed-store/internal/embedder"
 	"github.com/atroxxxxxx/embed-store/internal/httpapi"
 	"github.com/atroxxxxxx/embed-store/internal/importer"
 	"github.com/atroxxxxxx/embed-store/internal/logger"
@@ -55,14 +57,40 @@ func main() {
 	defer db.DB.Close()
 	log.Info("database successfully connected")
 
-	handler, err := httpapi.New(&db, log)
+	if len(cfg.Command) > 0 {
+		if err = admin.Run(rootCtx, &db, cfg.Command, os.Stdout, log); err != nil {
+			log.Fatal("command failed", zap.Error(err))
+		}
+		return
+	}
+
+	var embed httpapi.Embedder
+	if cfg.EmbedderCfg.URL != "" {
+		client, err := embedder.New(cfg.EmbedderCfg)
+		if err != nil {
+			log.Fatal("embedder init", zap.Error(err))
+		}
+		embed = client
+		log.Info("embedder configured", zap.String("url", cfg.EmbedderCfg.URL))
+	}
+
+	assigner := cluster.NewAssigner()
+	if err = assigner.Reload(rootCtx, &db); err != nil {
+		log.Warn("centroids not loaded, new chunks stay unclustered", zap.Error(err))
+	}
+	if cfg.ClusterCfg.BackfillInterval > 0 {
+		interval := time.Duration(cfg.ClusterCfg.BackfillInterval) * time.Second
+		go assigner.RunBackfill(rootCtx, &db, interval, cfg.ClusterCfg.BatchSize, log)
+	}
+
+	handler, err := httpapi.New(&db, embed, assigner, cfg.HTTPCfg, log)
 	if err != nil {
 		log.Fatal("handler error", zap.Error(err))
 	}
 
 	go func() {
 		if cfg.RunImport {
-			if err = importer.ExecImporter(rootCtx, &db, cfg, log, 20*time.Second); err != nil {
+			if err = importer.ExecImporter(rootCtx, &db, assigner, cfg, log, 20*time.Second); err != nil {
 				log.Error("import aborted", zap.Error(err))
 				return
 			}
@@ -72,6 +100,17 @@ func main() {
 				log.Error("cluster aborted", zap.Error(err))
 				return
 			}
+			if err = assigner.Reload(rootCtx, &db); err != nil {
+				log.Warn("centroid reload failed", zap.Error(err))
+				return
+			}
+			// Rows outside the run's sample still carry older cluster ids.
+			updated, err := assigner.Backfill(rootCtx, &db, cfg.ClusterCfg.BatchSize)
+			if err != nil {
+				log.Warn("cluster backfill failed", zap.Int("updated", updated), zap.Error(err))
+				return
+			}
+			log.Info("cluster ids backfilled", zap.Int("updated", updated))
 		}
 	}()
 
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS hackernews_embedding_hnsw_cosine_idx;
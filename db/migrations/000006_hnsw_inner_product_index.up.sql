@@ -0,0 +1,7 @@
+CREATE INDEX IF NOT EXISTS hackernews_embedding_hnsw_ip_idx
+ON hackernews
+USING hnsw (embedding vector_ip_ops)
+WITH (
+    m = 16,
+    ef_construction = 64
+);
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS hackernews_search_tsv_idx;
+
+ALTER TABLE hackernews
+DROP COLUMN IF EXISTS search_tsv;
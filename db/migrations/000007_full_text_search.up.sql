@@ -0,0 +1,10 @@
+ALTER TABLE hackernews
+ADD COLUMN IF NOT EXISTS search_tsv tsvector
+GENERATED ALWAYS AS (
+    setweight(to_tsvector('english', coalesce(title, '')), 'A') ||
+    setweight(to_tsvector('english', text), 'B')
+) STORED;
+
+CREATE INDEX IF NOT EXISTS hackernews_search_tsv_idx
+ON hackernews
+USING gin (search_tsv);
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS hackernews_time_id_idx;
@@ -0,0 +1,2 @@
+CREATE INDEX IF NOT EXISTS hackernews_time_id_idx
+ON hackernews (time, id);
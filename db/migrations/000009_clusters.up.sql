@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS clusters(
+    id INT PRIMARY KEY,
+    centroid vector(384) NOT NULL,
+    size INT NOT NULL DEFAULT 0,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
@@ -0,0 +1,7 @@
+ALTER TABLE clusters
+DROP COLUMN IF EXISTS inertia,
+DROP COLUMN IF EXISTS run_id;
+
+DROP TABLE IF EXISTS cluster_run_centroids;
+
+DROP TABLE IF EXISTS cluster_runs;
@@ -0,0 +1,21 @@
+CREATE TABLE IF NOT EXISTS cluster_runs(
+    id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    clusters INT NOT NULL,
+    points INT NOT NULL,
+    inertia DOUBLE PRECISION NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS cluster_run_centroids(
+    run_id BIGINT NOT NULL REFERENCES cluster_runs(id) ON DELETE CASCADE,
+    cluster_id INT NOT NULL,
+    centroid vector(384) NOT NULL,
+    size INT NOT NULL,
+    inertia DOUBLE PRECISION NOT NULL,
+
+    PRIMARY KEY (run_id, cluster_id)
+);
+
+ALTER TABLE clusters
+ADD COLUMN IF NOT EXISTS run_id BIGINT REFERENCES cluster_runs(id),
+ADD COLUMN IF NOT EXISTS inertia DOUBLE PRECISION NOT NULL DEFAULT 0;
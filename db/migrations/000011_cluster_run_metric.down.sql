@@ -0,0 +1,2 @@
+ALTER TABLE cluster_runs
+DROP COLUMN IF EXISTS metric;
@@ -0,0 +1,2 @@
+ALTER TABLE cluster_runs
+ADD COLUMN IF NOT EXISTS metric TEXT NOT NULL DEFAULT 'l2' CHECK (metric IN ('l2', 'cosine'));
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS cluster_evaluation_scores;
+
+DROP TABLE IF EXISTS cluster_evaluations;
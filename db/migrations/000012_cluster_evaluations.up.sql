@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS cluster_evaluations(
+    id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    metric TEXT NOT NULL,
+    points INT NOT NULL,
+    sample INT NOT NULL,
+    recommended_k INT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS cluster_evaluation_scores(
+    evaluation_id BIGINT NOT NULL REFERENCES cluster_evaluations(id) ON DELETE CASCADE,
+    k INT NOT NULL,
+    inertia DOUBLE PRECISION NOT NULL,
+    silhouette DOUBLE PRECISION NOT NULL,
+
+    PRIMARY KEY (evaluation_id, k)
+);
@@ -0,0 +1,13 @@
+ALTER TABLE cluster_run_centroids
+DROP COLUMN IF EXISTS label,
+DROP COLUMN IF EXISTS silhouette,
+DROP COLUMN IF EXISTS nearest_distance,
+DROP COLUMN IF EXISTS nearest_cluster,
+DROP COLUMN IF EXISTS mean_distance;
+
+ALTER TABLE clusters
+DROP COLUMN IF EXISTS label,
+DROP COLUMN IF EXISTS silhouette,
+DROP COLUMN IF EXISTS nearest_distance,
+DROP COLUMN IF EXISTS nearest_cluster,
+DROP COLUMN IF EXISTS mean_distance;
@@ -0,0 +1,13 @@
+ALTER TABLE clusters
+ADD COLUMN IF NOT EXISTS mean_distance DOUBLE PRECISION NOT NULL DEFAULT 0,
+ADD COLUMN IF NOT EXISTS nearest_cluster INT,
+ADD COLUMN IF NOT EXISTS nearest_distance DOUBLE PRECISION NOT NULL DEFAULT 0,
+ADD COLUMN IF NOT EXISTS silhouette DOUBLE PRECISION NOT NULL DEFAULT 0,
+ADD COLUMN IF NOT EXISTS label TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE cluster_run_centroids
+ADD COLUMN IF NOT EXISTS mean_distance DOUBLE PRECISION NOT NULL DEFAULT 0,
+ADD COLUMN IF NOT EXISTS nearest_cluster INT,
+ADD COLUMN IF NOT EXISTS nearest_distance DOUBLE PRECISION NOT NULL DEFAULT 0,
+ADD COLUMN IF NOT EXISTS silhouette DOUBLE PRECISION NOT NULL DEFAULT 0,
+ADD COLUMN IF NOT EXISTS label TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS hackernews_cluster_run_id_idx;
+
+ALTER TABLE hackernews
+DROP COLUMN IF EXISTS cluster_run_id;
@@ -0,0 +1,5 @@
+ALTER TABLE hackernews
+ADD COLUMN IF NOT EXISTS cluster_run_id BIGINT;
+
+CREATE INDEX IF NOT EXISTS hackernews_cluster_run_id_idx
+ON hackernews (cluster_run_id);
@@ -0,0 +1,159 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	database "github.com/atroxxxxxx/embed-store/internal/db"
+	"go.uber.org/zap"
+)
+
+const progressInterval = 5 * time.Second
+
+var ErrUnknownCommand = errors.New("unknown command")
+
+const usage = `usage:
+  index list
+  index create -name NAME -method hnsw|ivfflat [-metric l2|cosine|inner_product] [-lists N] [-m N] [-ef-construction N]
+  index drop -name NAME
+  index progress
+  eval [-samples N] [-k N] [-metric l2|cosine|inner_product] [-ef-search N] [-probes N] [-nprobe N]
+  clusters sweep [-min N] [-max N] [-step N] [-sample N] [-limit N] [-metric l2|cosine] [-seed N]
+  clusters report`
+
+// Run executes a one-shot administrative command given as positional
+// arguments, e.g. "index list" or "eval", and writes a human readable result to out.
+func Run(ctx context.Context, db *database.Database, args []string, out io.Writer, log *zap.Logger) error {
+	if len(args) > 0 && args[0] == "eval" {
+		return evaluate(ctx, db, args[1:], out, log)
+	}
+	if len(args) < 2 {
+		return fmt.Errorf("%w: %v\n%s", ErrUnknownCommand, args, usage)
+	}
+	switch args[0] {
+	case "index":
+		return runIndex(ctx, db, args[1:], out, log)
+	case "clusters":
+		return runClusters(ctx, db, args[1:], out, log)
+	default:
+		return fmt.Errorf("%w: %v\n%s", ErrUnknownCommand, args, usage)
+	}
+}
+
+func runIndex(ctx context.Context, db *database.Database, args []string, out io.Writer, log *zap.Logger) error {
+	switch args[0] {
+	case "list":
+		return listIndexes(ctx, db, out)
+	case "create":
+		return createIndex(ctx, db, args[1:], out, log)
+	case "drop":
+		return dropIndex(ctx, db, args[1:], log)
+	case "progress":
+		return printProgress(ctx, db, out)
+	default:
+		return fmt.Errorf("%w: index %s\n%s", ErrUnknownCommand, args[0], usage)
+	}
+}
+
+func listIndexes(ctx context.Context, db *database.Database, out io.Writer) error {
+	indexes, err := db.ListIndexes(ctx)
+	if err != nil {
+		return err
+	}
+
+	writer := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintln(writer, "NAME\tMETHOD\tOPCLASS\tOPTIONS\tSIZE\tVALID")
+	for _, index := range indexes {
+		_, _ = fmt.Fprintf(writer, "%s\t%s\t%s\t%v\t%d\t%t\n",
+			index.Name, index.Method, index.OpClass, index.Options, index.SizeBytes, index.Valid)
+	}
+	return writer.Flush()
+}
+
+func createIndex(ctx context.Context, db *database.Database, args []string, out io.Writer, log *zap.Logger) error {
+	var (
+		spec   database.IndexSpec
+		metric string
+	)
+	flags := flag.NewFlagSet("index create", flag.ContinueOnError)
+	flags.SetOutput(out)
+	flags.StringVar(&spec.Name, "name", "", "index name")
+	flags.StringVar(&spec.Method, "method", database.IndexHNSW, "hnsw or ivfflat")
+	flags.StringVar(&metric, "metric", string(database.MetricL2), "l2, cosine or inner_product")
+	flags.IntVar(&spec.Lists, "lists", 0, "ivfflat lists")
+	flags.IntVar(&spec.M, "m", 0, "hnsw m")
+	flags.IntVar(&spec.EfConstruction, "ef-construction", 0, "hnsw ef_construction")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	var err error
+	if spec.Metric, err = database.ParseMetric(metric); err != nil {
+		return err
+	}
+	if err = spec.Validate(); err != nil {
+		return err
+	}
+
+	log.Info("index build started", zap.String("name", spec.Name), zap.String("method", spec.Method))
+	done := make(chan error, 1)
+	go func() {
+		done <- db.CreateIndex(ctx, spec)
+	}()
+
+	ticker := time.NewTicker(progressInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case err = <-done:
+			if err != nil {
+				return err
+			}
+			log.Info("index build finished", zap.String("name", spec.Name))
+			return nil
+		case <-ticker.C:
+			if err := printProgress(ctx, db, out); err != nil {
+				log.Warn("index progress", zap.Error(err))
+			}
+		}
+	}
+}
+
+func dropIndex(ctx context.Context, db *database.Database, args []string, log *zap.Logger) error {
+	var name string
+	flags := flag.NewFlagSet("index drop", flag.ContinueOnError)
+	flags.StringVar(&name, "name", "", "index name")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if err := db.DropIndex(ctx, name); err != nil {
+		return err
+	}
+	log.Info("index dropped", zap.String("name", name))
+	return nil
+}
+
+func printProgress(ctx context.Context, db *database.Database, out io.Writer) error {
+	progress, err := db.IndexProgress(ctx)
+	if err != nil {
+		return err
+	}
+	if len(progress) == 0 {
+		_, _ = fmt.Fprintln(out, "no index builds in progress")
+		return nil
+	}
+
+	writer := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintln(writer, "PID\tINDEX\tPHASE\tBLOCKS\tTUPLES")
+	for _, item := range progress {
+		_, _ = fmt.Fprintf(writer, "%d\t%s\t%s\t%d/%d\t%d/%d\n", item.PID, item.Index, item.Phase,
+			item.BlocksDone, item.BlocksTotal, item.TuplesDone, item.TuplesTotal)
+	}
+	return writer.Flush()
+}
@@ -0,0 +1,70 @@
+package admin
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/atroxxxxxx/embed-store/internal/cluster"
+	database "github.com/atroxxxxxx/embed-store/internal/db"
+	"go.uber.org/zap"
+)
+
+func runClusters(ctx context.Context, db *database.Database, args []string, out io.Writer, log *zap.Logger) error {
+	switch args[0] {
+	case "sweep":
+		return sweepClusters(ctx, db, args[1:], out, log)
+	case "report":
+		evaluation, err := db.LatestClusterEvaluation(ctx)
+		if err != nil {
+			return err
+		}
+		return printEvaluation(out, evaluation)
+	default:
+		return fmt.Errorf("%w: clusters %s\n%s", ErrUnknownCommand, args[0], usage)
+	}
+}
+
+func sweepClusters(ctx context.Context, db *database.Database, args []string, out io.Writer, log *zap.Logger) error {
+	cfg := cluster.ClusterConfig{Mode: cluster.ModeSweep}
+	flags := flag.NewFlagSet("clusters sweep", flag.ContinueOnError)
+	flags.SetOutput(out)
+	flags.IntVar(&cfg.SweepMin, "min", 8, "smallest k")
+	flags.IntVar(&cfg.SweepMax, "max", 128, "largest k")
+	flags.IntVar(&cfg.SweepStep, "step", 8, "k increment")
+	flags.IntVar(&cfg.SilhouetteSample, "sample", 1000, "points used for the silhouette score")
+	flags.IntVar(&cfg.Limit, "limit", 20000, "rows loaded for clustering")
+	flags.StringVar(&cfg.Metric, "metric", string(database.MetricL2), "l2 or cosine")
+	flags.IntVar(&cfg.Seed, "seed", 0, "k-means++ seed, 0 seeds from the clock")
+	flags.IntVar(&cfg.Iters, "iters", 10, "k-means iterations per k")
+	flags.IntVar(&cfg.Workers, "workers", 4, "assignment workers")
+	flags.Float64Var(&cfg.Tolerance, "tolerance", 1e-4, "early stopping tolerance")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	evaluation, err := cluster.Sweep(ctx, db, cfg, log)
+	if err != nil {
+		return err
+	}
+	return printEvaluation(out, evaluation)
+}
+
+func printEvaluation(out io.Writer, evaluation *database.ClusterEvaluation) error {
+	_, _ = fmt.Fprintf(out, "evaluation %d at %s: metric %s, %d points, silhouette sample %d\n",
+		evaluation.ID, evaluation.CreatedAt.Format("2006-01-02 15:04:05"), evaluation.Metric,
+		evaluation.Points, evaluation.Sample)
+
+	writer := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintln(writer, "K\tINERTIA\tSILHOUETTE\t")
+	for _, score := range evaluation.Scores {
+		mark := ""
+		if score.K == evaluation.RecommendedK {
+			mark = "recommended"
+		}
+		_, _ = fmt.Fprintf(writer, "%d\t%.4f\t%.4f\t%s\n", score.K, score.Inertia, score.Silhouette, mark)
+	}
+	return writer.Flush()
+}
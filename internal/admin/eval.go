@@ -0,0 +1,135 @@
+package admin
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"slices"
+	"time"
+
+	database "github.com/atroxxxxxx/embed-store/internal/db"
+	"go.uber.org/zap"
+)
+
+type evalStats struct {
+	recall    []float64
+	approx    []time.Duration
+	exact     []time.Duration
+	emptyHits int
+}
+
+// evaluate samples stored embeddings, searches each one with the index and
+// with a sequential scan, and reports recall@k of the index against the scan.
+// The sampled row itself is excluded so it does not trivially match.
+func evaluate(ctx context.Context, db *database.Database, args []string, out io.Writer, log *zap.Logger) error {
+	var (
+		samples int
+		k       int
+		metric  string
+		opts    database.SearchOptions
+	)
+	flags := flag.NewFlagSet("eval", flag.ContinueOnError)
+	flags.SetOutput(out)
+	flags.IntVar(&samples, "samples", 100, "number of stored embeddings to query with")
+	flags.IntVar(&k, "k", 10, "neighbours per query")
+	flags.StringVar(&metric, "metric", string(database.MetricL2), "l2, cosine or inner_product")
+	flags.IntVar(&opts.EfSearch, "ef-search", 0, "hnsw.ef_search for the approximate run")
+	flags.IntVar(&opts.Probes, "probes", 0, "ivfflat.probes for the approximate run")
+	flags.IntVar(&opts.NProbe, "nprobe", 0, "search only the nearest stored clusters in the approximate run")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if samples <= 0 || k <= 0 {
+		return fmt.Errorf("samples and k must be positive")
+	}
+
+	var err error
+	if opts.Metric, err = database.ParseMetric(metric); err != nil {
+		return err
+	}
+
+	points, err := db.SampleEmbeddings(ctx, samples)
+	if err != nil {
+		return err
+	}
+	log.Info("evaluation started", zap.Int("samples", len(points)), zap.Int("k", k))
+
+	var stats evalStats
+	for _, point := range points {
+		approxOpts, exactOpts := opts, opts
+		// The exact pass needs a transaction for its SET LOCAL; time the
+		// index pass through one as well so the round trips match.
+		approxOpts.Transaction = true
+		approxOpts.Filter.ExcludeIDs = []int64{point.ID}
+		exactOpts.Filter.ExcludeIDs = []int64{point.ID}
+		exactOpts.EfSearch, exactOpts.Probes, exactOpts.NProbe, exactOpts.Exact = 0, 0, 0, true
+
+		start := time.Now()
+		approx, err := db.Search(ctx, &point.Embedding, k, approxOpts)
+		if err != nil {
+			return err
+		}
+		stats.approx = append(stats.approx, time.Since(start))
+
+		start = time.Now()
+		exact, err := db.Search(ctx, &point.Embedding, k, exactOpts)
+		if err != nil {
+			return err
+		}
+		stats.exact = append(stats.exact, time.Since(start))
+
+		if len(exact) == 0 {
+			stats.emptyHits++
+			continue
+		}
+		stats.recall = append(stats.recall, recall(approx, exact))
+	}
+
+	printEval(out, k, &stats)
+	return nil
+}
+
+func recall(approx, exact []*database.SearchHit) float64 {
+	found := make(map[int64]struct{}, len(approx))
+	for _, hit := range approx {
+		found[hit.ID] = struct{}{}
+	}
+	matched := 0
+	for _, hit := range exact {
+		if _, ok := found[hit.ID]; ok {
+			matched++
+		}
+	}
+	return float64(matched) / float64(len(exact))
+}
+
+func percentile(values []time.Duration, p float64) time.Duration {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := slices.Clone(values)
+	slices.Sort(sorted)
+	idx := int(p * float64(len(sorted)-1))
+	return sorted[idx]
+}
+
+func printEval(out io.Writer, k int, stats *evalStats) {
+	mean := 0.0
+	for _, value := range stats.recall {
+		mean += value
+	}
+	if len(stats.recall) > 0 {
+		mean /= float64(len(stats.recall))
+	}
+
+	_, _ = fmt.Fprintf(out, "queries: %d (skipped without neighbours: %d)\n", len(stats.recall), stats.emptyHits)
+	_, _ = fmt.Fprintf(out, "recall@%d: %.4f\n", k, mean)
+	for _, run := range []struct {
+		name      string
+		latencies []time.Duration
+	}{{"index", stats.approx}, {"exact", stats.exact}} {
+		_, _ = fmt.Fprintf(out, "%s latency: p50 %s  p95 %s  p99 %s\n", run.name,
+			percentile(run.latencies, 0.50), percentile(run.latencies, 0.95), percentile(run.latencies, 0.99))
+	}
+}
@@ -0,0 +1,148 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/atroxxxxxx/embed-store/internal/db"
+	"go.uber.org/zap"
+)
+
+const defaultBackfillBatch = 1000
+
+type CentroidStore interface {
+	LoadCentroids(ctx context.Context) (int64, []int32, [][]float32, error)
+}
+
+type BackfillStore interface {
+	CentroidStore
+	UnclusteredPoints(ctx context.Context, runID, afterID int64, limit int) ([]*db.ClusterPoint, error)
+	UpdateClusterIDs(ctx context.Context, runID int64, ids []int64, clusterIDs []int32) error
+}
+
+// Assigner maps new vectors to the nearest persisted centroid. It is safe for
+// concurrent use and assigns nothing until centroids have been loaded.
+type Assigner struct {
+	mutex     sync.RWMutex
+	runID     int64
+	ids       []int32
+	centroids [][]float32
+}
+
+func NewAssigner() *Assigner {
+	return &Assigner{}
+}
+
+func (obj *Assigner) Reload(ctx context.Context, store CentroidStore) error {
+	runID, ids, centroids, err := store.LoadCentroids(ctx)
+	if err != nil {
+		return err
+	}
+
+	obj.mutex.Lock()
+	defer obj.mutex.Unlock()
+	obj.runID, obj.ids, obj.centroids = runID, ids, centroids
+	return nil
+}
+
+// Assign returns the cluster id of vec and the run it belongs to.
+func (obj *Assigner) Assign(vec []float32) (int32, int64, bool) {
+	obj.mutex.RLock()
+	defer obj.mutex.RUnlock()
+	if len(obj.centroids) == 0 || len(vec) != len(obj.centroids[0]) {
+		return 0, 0, false
+	}
+	return obj.ids[findNearestCentroid(vec, obj.centroids)], obj.runID, true
+}
+
+// AssignChunks sets ClusterID and ClusterRunID on every chunk it can assign.
+func (obj *Assigner) AssignChunks(chunks ...*db.Chunk) {
+	for _, chunk := range chunks {
+		if chunk == nil {
+			continue
+		}
+		if clusterID, runID, ok := obj.Assign(chunk.Embedding.Slice()); ok {
+			chunk.ClusterID, chunk.ClusterRunID = &clusterID, &runID
+		}
+	}
+}
+
+// Backfill assigns every row that has no cluster_id or one from an older run
+// than the loaded centroids, and returns how many rows were updated.
+func (obj *Assigner) Backfill(ctx context.Context, store BackfillStore, batchSize int) (int, error) {
+	if batchSize <= 0 {
+		batchSize = defaultBackfillBatch
+	}
+	obj.mutex.RLock()
+	runID := obj.runID
+	obj.mutex.RUnlock()
+	if runID == 0 {
+		return 0, nil
+	}
+
+	updated := 0
+	var afterID int64
+	for {
+		points, err := store.UnclusteredPoints(ctx, runID, afterID, batchSize)
+		if err != nil {
+			return updated, err
+		}
+		if len(points) == 0 {
+			return updated, nil
+		}
+		afterID = points[len(points)-1].ID
+
+		ids := make([]int64, 0, len(points))
+		clusterIDs := make([]int32, 0, len(points))
+		for _, point := range points {
+			clusterID, assignedRunID, ok := obj.Assign(point.Embedding.Slice())
+			if !ok || assignedRunID != runID {
+				// The centroids were reloaded meanwhile; the next round picks up.
+				return updated, nil
+			}
+			ids = append(ids, point.ID)
+			clusterIDs = append(clusterIDs, clusterID)
+		}
+		if err = store.UpdateClusterIDs(ctx, runID, ids, clusterIDs); err != nil {
+			return updated, fmt.Errorf("backfill after id %d: %w", afterID, err)
+		}
+		updated += len(ids)
+	}
+}
+
+// RunBackfill reloads the centroids and backfills cluster ids every interval
+// until ctx is done. This picks up rows inserted before centroids existed,
+// rows a full-mode run did not sample, and rows assigned against the previous
+// centroids before this process reloaded them.
+func (obj *Assigner) RunBackfill(
+	ctx context.Context,
+	store BackfillStore,
+	interval time.Duration,
+	batchSize int,
+	log *zap.Logger,
+) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := obj.Reload(ctx, store); err != nil {
+				log.Warn("centroid reload failed", zap.Error(err))
+				continue
+			}
+			updated, err := obj.Backfill(ctx, store, batchSize)
+			if err != nil {
+				log.Warn("cluster backfill failed", zap.Int("updated", updated), zap.Error(err))
+				continue
+			}
+			if updated > 0 {
+				log.Info("cluster ids backfilled", zap.Int("updated", updated))
+			}
+		}
+	}
+}
@@ -2,8 +2,13 @@ package cluster
 
 import (
 	"errors"
+	"math"
 	"math/rand"
+	"slices"
 	"time"
+
+	"github.com/atroxxxxxx/embed-store/internal/db"
+	"go.uber.org/zap"
 )
 
 type ClusterConfig struct {
@@ -12,15 +17,71 @@ type ClusterConfig struct {
 	Workers   int
 	Limit     int
 	BatchSize int
+	// BackfillInterval is in seconds; 0 disables the cluster id backfill.
+	BackfillInterval int
+	// Seed makes the k-means++ seeding reproducible; 0 seeds from the clock.
+	Seed int
+	// Tolerance stops the iterations early once the share of points that
+	// changed cluster or the largest centroid shift drops to it.
+	Tolerance float64
+	// Mode is ModeFull (k-means over a Limit sample) or ModeMiniBatch
+	// (streaming over the whole table).
+	Mode string
+	// Epochs bounds the training passes in ModeMiniBatch.
+	Epochs int
+	// Metric is l2 or cosine; cosine runs spherical k-means on unit vectors.
+	Metric string
+	// SweepMin, SweepMax and SweepStep give the k values tried by ModeSweep;
+	// SilhouetteSample bounds the points used for the silhouette score and is
+	// capped at maxSilhouetteSample.
+	SweepMin         int
+	SweepMax         int
+	SweepStep        int
+	SilhouetteSample int
 }
 
+const (
+	ModeFull      = "full"
+	ModeMiniBatch = "minibatch"
+	// ModeSweep only evaluates a range of k and stores the report.
+	ModeSweep = "sweep"
+)
+
 var (
 	ErrEmptyDataset       = errors.New("empty dataset")
 	ErrInvalidClusterSize = errors.New("clusters must be > 0")
 	ErrInvalidVectorDims  = errors.New("invalid vector dims")
+	ErrUnknownMode        = errors.New("cluster mode must be full, minibatch or sweep")
+	ErrUnsupportedMetric  = errors.New("cluster metric must be l2 or cosine")
+	ErrSweepRange         = errors.New("sweep min k exceeds the number of points")
 )
 
-func kMeans(vectors [][]float32, cfg ClusterConfig) ([]int32, error) {
+type Result struct {
+	Assignments []int32
+	Centroids   [][]float32
+	// Inertia is the per-cluster sum of distances to the centroid: squared L2
+	// for l2 runs and cosine distance for cosine runs.
+	Inertia    []float64
+	Iterations []Iteration
+}
+
+type Iteration struct {
+	Inertia  float64
+	Changed  int
+	Shift    float64
+	Reseeded int
+}
+
+// Sizes counts the points assigned to each centroid.
+func (obj *Result) Sizes() []int {
+	sizes := make([]int, len(obj.Centroids))
+	for _, clusterID := range obj.Assignments {
+		sizes[clusterID]++
+	}
+	return sizes
+}
+
+func kMeans(vectors [][]float32, cfg ClusterConfig, log *zap.Logger) (*Result, error) {
 	if len(vectors) == 0 {
 		return nil, ErrEmptyDataset
 	}
@@ -33,6 +94,9 @@ func kMeans(vectors [][]float32, cfg ClusterConfig) ([]int32, error) {
 	if cfg.Workers <= 0 {
 		cfg.Workers = 1
 	}
+	if cfg.Tolerance < 0 {
+		cfg.Tolerance = 0
+	}
 
 	dim := len(vectors[0])
 	for i := 1; i < len(vectors); i++ {
@@ -46,36 +110,117 @@ func kMeans(vectors [][]float32, cfg ClusterConfig) ([]int32, error) {
 		clusterCount = len(vectors)
 	}
 
-	centroids := initCentroidsRandom(vectors, clusterCount)
-
-	for iter := 0; iter < cfg.Iters; iter++ {
-		assignments := assignClusters(vectors, centroids, cfg.Workers)
-		centroids = recomputeCentroids(vectors, assignments, clusterCount, dim, cfg.Workers, centroids)
+	metric := db.Metric(cfg.Metric)
+	distance := distanceFor(metric)
+	if metric == db.MetricCosine {
+		for _, vec := range vectors {
+			normalize(vec)
+		}
+	}
+	centroids := initCentroidsPlusPlus(vectors, clusterCount, newRand(cfg.Seed), distance)
+
+	var (
+		assignments []int32
+		history     []Iteration
+	)
+	for iter := range cfg.Iters {
+		next, distances := assignClusters(vectors, centroids, cfg.Workers, distance)
+		stats := Iteration{Changed: countChanged(assignments, next)}
+		for _, distance := range distances {
+			stats.Inertia += float64(distance)
+		}
+		assignments = next
 
-		// если хочешь, можно вернуть assignments после последней итерации
-		if iter == cfg.Iters-1 {
-			return assignments, nil
+		updated, counts := recomputeCentroids(vectors, assignments, clusterCount, dim, cfg.Workers, centroids)
+		stats.Reseeded = reseedEmpty(vectors, distances, updated, counts)
+		if metric == db.MetricCosine {
+			for _, centroid := range updated {
+				normalize(centroid)
+			}
+		}
+		stats.Shift = maxShift(centroids, updated)
+		centroids = updated
+		history = append(history, stats)
+
+		log.Info("kmeans iteration",
+			zap.Int("iter", iter+1),
+			zap.Float64("inertia", stats.Inertia),
+			zap.Int("changed", stats.Changed),
+			zap.Float64("shift", stats.Shift),
+			zap.Int("reseeded", stats.Reseeded),
+		)
+
+		if stats.Reseeded == 0 && (float64(stats.Changed) <= cfg.Tolerance*float64(len(vectors)) ||
+			stats.Shift <= cfg.Tolerance) {
+			break
 		}
 	}
 
-	return nil, nil
+	// Assign once more against the final centroids so that every point sits in
+	// the cluster of its nearest centroid, as new chunks will.
+	assignments, _ = assignClusters(vectors, centroids, cfg.Workers, distance)
+	return &Result{
+		Assignments: assignments,
+		Centroids:   centroids,
+		Inertia:     clusterInertia(vectors, assignments, centroids, distance),
+		Iterations:  history,
+	}, nil
+}
+
+func clusterInertia(vectors [][]float32, assignments []int32, centroids [][]float32, distance distanceFunc) []float64 {
+	inertia := make([]float64, len(centroids))
+	for i, vec := range vectors {
+		clusterID := assignments[i]
+		inertia[clusterID] += float64(distance(vec, centroids[clusterID]))
+	}
+	return inertia
 }
 
-func initCentroidsRandom(vectors [][]float32, count int) [][]float32 {
-	rnd := rand.New(rand.NewSource(time.Now().UnixNano()))
+// newRand uses the clock when seed is 0, so only explicitly seeded runs are
+// reproducible.
+func newRand(seed int) *rand.Rand {
+	if seed == 0 {
+		return rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+	return rand.New(rand.NewSource(int64(seed)))
+}
+
+// initCentroidsPlusPlus picks seeds with k-means++: each next seed is drawn
+// with probability proportional to its distance to the closest seed chosen so
+// far. distance must grow like squared L2: squareDistance does, and so does
+// cosineDistance on unit vectors, where it equals half the squared L2.
+func initCentroidsPlusPlus(vectors [][]float32, count int, rnd *rand.Rand, distance distanceFunc) [][]float32 {
 	centroids := make([][]float32, 0, count)
+	first := slices.Clone(vectors[rnd.Intn(len(vectors))])
+	centroids = append(centroids, first)
+
+	closest := make([]float64, len(vectors))
+	for i, vec := range vectors {
+		closest[i] = float64(distance(vec, first))
+	}
 
-	used := make(map[int]struct{}, count)
 	for len(centroids) < count {
+		total := 0.0
+		for _, distance := range closest {
+			total += distance
+		}
+
 		idx := rnd.Intn(len(vectors))
-		if _, ok := used[idx]; ok {
-			continue
+		if total > 0 {
+			target := rnd.Float64() * total
+			for idx = 0; idx < len(closest)-1; idx++ {
+				target -= closest[idx]
+				if target < 0 {
+					break
+				}
+			}
 		}
-		used[idx] = struct{}{}
 
-		centroid := make([]float32, len(vectors[idx]))
-		copy(centroid, vectors[idx])
+		centroid := slices.Clone(vectors[idx])
 		centroids = append(centroids, centroid)
+		for i, vec := range vectors {
+			closest[i] = min(closest[i], float64(distance(vec, centroid)))
+		}
 	}
 
 	return centroids
@@ -86,9 +231,17 @@ type assignJob struct {
 	end   int
 }
 
-func assignClusters(vectors [][]float32, centroids [][]float32, workers int) []int32 {
+// assignClusters returns the nearest centroid of every vector and the
+// distance to it.
+func assignClusters(
+	vectors [][]float32,
+	centroids [][]float32,
+	workers int,
+	distance distanceFunc,
+) ([]int32, []float32) {
 	n := len(vectors)
 	assignments := make([]int32, n)
+	distances := make([]float32, n)
 
 	jobs := make(chan assignJob)
 	done := make(chan struct{}, workers)
@@ -97,7 +250,8 @@ func assignClusters(vectors [][]float32, centroids [][]float32, workers int) []i
 		go func() {
 			for job := range jobs {
 				for i := job.start; i < job.end; i++ {
-					assignments[i] = int32(findNearestCentroid(vectors[i], centroids))
+					idx, dist := nearestCentroid(vectors[i], centroids, distance)
+					assignments[i], distances[i] = int32(idx), dist
 				}
 			}
 			done <- struct{}{}
@@ -118,21 +272,73 @@ func assignClusters(vectors [][]float32, centroids [][]float32, workers int) []i
 		<-done
 	}
 
-	return assignments
+	return assignments, distances
 }
 
+// findNearestCentroid ranks by squared L2. For the unit-length centroids of
+// a cosine run this gives the same order as cosine similarity for any vec,
+// so it serves both metrics without normalising vec.
 func findNearestCentroid(vec []float32, centroids [][]float32) int {
+	idx, _ := nearestCentroid(vec, centroids, squareDistance)
+	return idx
+}
+
+func nearestCentroid(vec []float32, centroids [][]float32, distance distanceFunc) (int, float32) {
 	bestIndex := 0
-	bestDist := squareDistance(vec, centroids[0])
+	bestDist := distance(vec, centroids[0])
 
 	for c := 1; c < len(centroids); c++ {
-		d := squareDistance(vec, centroids[c])
+		d := distance(vec, centroids[c])
 		if d < bestDist {
 			bestDist = d
 			bestIndex = c
 		}
 	}
-	return bestIndex
+	return bestIndex, bestDist
+}
+
+func countChanged(prev, next []int32) int {
+	if len(prev) != len(next) {
+		return len(next)
+	}
+	changed := 0
+	for i := range next {
+		if prev[i] != next[i] {
+			changed++
+		}
+	}
+	return changed
+}
+
+// reseedEmpty moves every empty centroid onto the point that is currently
+// worst served by its own centroid; that point's distance is zeroed so it is
+// not picked twice.
+func reseedEmpty(vectors [][]float32, distances []float32, centroids [][]float32, counts []int) int {
+	reseeded := 0
+	for c, count := range counts {
+		if count > 0 {
+			continue
+		}
+		worst := 0
+		for i := 1; i < len(distances); i++ {
+			if distances[i] > distances[worst] {
+				worst = i
+			}
+		}
+		copy(centroids[c], vectors[worst])
+		distances[worst] = 0
+		reseeded++
+	}
+	return reseeded
+}
+
+// maxShift is the largest Euclidean distance any centroid moved.
+func maxShift(prev, next [][]float32) float64 {
+	shift := 0.0
+	for c := range next {
+		shift = max(shift, math.Sqrt(float64(squareDistance(prev[c], next[c]))))
+	}
+	return shift
 }
 
 type recomputeJob struct {
@@ -147,7 +353,7 @@ func recomputeCentroids(
 	dim int,
 	workers int,
 	prevCentroids [][]float32,
-) [][]float32 {
+) ([][]float32, []int) {
 	// localSums[worker][cluster][dim]
 	localSums := make([][][]float32, workers)
 	localCounts := make([][]int, workers)
@@ -227,5 +433,5 @@ func recomputeCentroids(
 		}
 	}
 
-	return centroids
+	return centroids, totalCounts
 }
@@ -0,0 +1,140 @@
+package cluster
+
+import (
+	"context"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/atroxxxxxx/embed-store/internal/db"
+	"go.uber.org/zap"
+)
+
+const (
+	labelTerms           = 3
+	labelTextsPerCluster = 200
+	minTermLength        = 3
+)
+
+// stopWords covers common English words plus the HTML and URL fragments found
+// in Hacker News comment text.
+var stopWords = toSet(
+	"the", "and", "for", "are", "but", "not", "you", "all", "any", "can", "had", "her", "was", "one", "our",
+	"out", "has", "him", "his", "how", "its", "may", "new", "now", "old", "see", "two", "who", "did", "get",
+	"let", "say", "she", "too", "use", "that", "with", "have", "this", "will", "your", "from", "they", "know",
+	"want", "been", "good", "much", "some", "time", "very", "when", "come", "here", "just", "like", "long",
+	"make", "many", "more", "only", "over", "such", "take", "than", "them", "well", "were", "what", "which",
+	"their", "there", "these", "those", "would", "could", "should", "about", "other", "into", "then", "also",
+	"because", "being", "does", "doesn", "don", "even", "most", "need", "really", "same", "still", "thing",
+	"things", "think", "where", "while", "why", "way", "yes", "both", "each", "few", "own", "off",
+	"quot", "amp", "href", "http", "https", "www", "com", "org", "rel", "nofollow", "html",
+)
+
+func toSet(words ...string) map[string]struct{} {
+	out := make(map[string]struct{}, len(words))
+	for _, word := range words {
+		out[word] = struct{}{}
+	}
+	return out
+}
+
+// labelClusters names the clusters of runID by their most distinctive terms.
+// Labels are cosmetic, so a failure is only logged.
+func labelClusters(ctx context.Context, database *db.Database, runID int64, log *zap.Logger) {
+	texts, err := database.ClusterTexts(ctx, labelTextsPerCluster)
+	if err != nil {
+		log.Warn("cluster labels", zap.Error(err))
+		return
+	}
+	labels := tfidfLabels(texts, labelTerms)
+	if err = database.SetClusterLabels(ctx, runID, labels); err != nil {
+		log.Warn("cluster labels", zap.Error(err))
+		return
+	}
+	log.Info("cluster labels", zap.Int64("run_id", runID), zap.Int("clusters", len(labels)))
+}
+
+// tfidfLabels treats every text as a document and scores each term of a
+// cluster by its frequency inside the cluster times its inverse document
+// frequency over all texts. Terms seen in a single member are skipped unless
+// the cluster has only one member.
+func tfidfLabels(texts []*db.ClusterText, terms int) map[int32]string {
+	type clusterTerms struct {
+		counts  map[string]int
+		docs    map[string]int
+		total   int
+		members int
+	}
+
+	df := make(map[string]int)
+	clusters := make(map[int32]*clusterTerms)
+	for _, text := range texts {
+		stats := clusters[text.ClusterID]
+		if stats == nil {
+			stats = &clusterTerms{counts: make(map[string]int), docs: make(map[string]int)}
+			clusters[text.ClusterID] = stats
+		}
+		stats.members++
+
+		seen := make(map[string]struct{})
+		for _, token := range tokenize(text.Title + " " + text.Text) {
+			stats.counts[token]++
+			stats.total++
+			if _, ok := seen[token]; !ok {
+				seen[token] = struct{}{}
+				stats.docs[token]++
+				df[token]++
+			}
+		}
+	}
+
+	type termScore struct {
+		term  string
+		score float64
+	}
+	labels := make(map[int32]string, len(clusters))
+	for clusterID, stats := range clusters {
+		scores := make([]termScore, 0, len(stats.counts))
+		for term, count := range stats.counts {
+			if stats.members > 1 && stats.docs[term] < 2 {
+				continue
+			}
+			tf := float64(count) / float64(stats.total)
+			idf := math.Log(float64(len(texts)) / float64(df[term]))
+			scores = append(scores, termScore{term: term, score: tf * idf})
+		}
+		sort.Slice(scores, func(i, j int) bool {
+			if scores[i].score != scores[j].score {
+				return scores[i].score > scores[j].score
+			}
+			return scores[i].term < scores[j].term
+		})
+
+		top := make([]string, 0, terms)
+		for _, term := range scores[:min(terms, len(scores))] {
+			top = append(top, term.term)
+		}
+		labels[clusterID] = strings.Join(top, ", ")
+	}
+	return labels
+}
+
+// tokenize lowercases text and splits it into letter runs, dropping short
+// tokens and stop words.
+func tokenize(text string) []string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+	out := words[:0]
+	for _, word := range words {
+		if len([]rune(word)) < minTermLength {
+			continue
+		}
+		if _, ok := stopWords[word]; ok {
+			continue
+		}
+		out = append(out, word)
+	}
+	return out
+}
@@ -1,5 +1,33 @@
 package cluster
 
+import (
+	"math"
+
+	"github.com/atroxxxxxx/embed-store/internal/db"
+)
+
+type distanceFunc func(vec1, vec2 []float32) float32
+
+// distanceFor returns the distance k-means minimises. Cosine assumes unit
+// vectors: the caller normalises points and centroids (spherical k-means).
+func distanceFor(metric db.Metric) distanceFunc {
+	if metric == db.MetricCosine {
+		return cosineDistance
+	}
+	return squareDistance
+}
+
+// metricDistance is the distance reported in quality metrics: Euclidean (not
+// squared) for l2, so that it is a true metric, and cosine distance otherwise.
+func metricDistance(metric db.Metric) distanceFunc {
+	if metric == db.MetricCosine {
+		return cosineDistance
+	}
+	return func(vec1, vec2 []float32) float32 {
+		return float32(math.Sqrt(float64(squareDistance(vec1, vec2))))
+	}
+}
+
 func squareDistance(vec1, vec2 []float32) float32 {
 	var sum float32
 	for i := range len(vec1) {
@@ -8,3 +36,26 @@ func squareDistance(vec1, vec2 []float32) float32 {
 	}
 	return sum
 }
+
+func cosineDistance(vec1, vec2 []float32) float32 {
+	var dot float32
+	for i := range len(vec1) {
+		dot += vec1[i] * vec2[i]
+	}
+	return 1 - dot
+}
+
+// normalize scales vec to unit length in place; zero vectors are left as is.
+func normalize(vec []float32) {
+	var sum float64
+	for _, value := range vec {
+		sum += float64(value) * float64(value)
+	}
+	if sum == 0 {
+		return
+	}
+	inv := float32(1 / math.Sqrt(sum))
+	for i := range vec {
+		vec[i] *= inv
+	}
+}
@@ -0,0 +1,205 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"time"
+
+	"github.com/atroxxxxxx/embed-store/internal/db"
+	"go.uber.org/zap"
+)
+
+// runMiniBatch clusters the whole table with bounded memory: seeds come from
+// a ClusterSource sample, centroids are trained with mini-batch k-means over
+// keyset pages of BatchSize rows, and a final streaming pass writes cluster_id
+// to every row.
+func runMiniBatch(ctx context.Context, database *db.Database, cfg ClusterConfig, log *zap.Logger) error {
+	start := time.Now()
+	seeds, err := database.ClusterSource(ctx, cfg.Limit)
+	if err != nil {
+		return fmt.Errorf("cluster source: %w", err)
+	}
+	if len(seeds) == 0 {
+		log.Warn("cluster source returned 0 rows")
+		return nil
+	}
+
+	metric := db.Metric(cfg.Metric)
+	vectors := pointVectors(seeds, metric)
+	centroids := initCentroidsPlusPlus(vectors, min(cfg.Clusters, len(vectors)), newRand(cfg.Seed), distanceFor(metric))
+
+	centroids, err = trainMiniBatch(ctx, database, centroids, cfg, log)
+	if err != nil {
+		return err
+	}
+
+	run, meanDistance, err := assignAll(ctx, database, centroids, cfg, log)
+	if err != nil {
+		return err
+	}
+	// The silhouette is sampled from the seed rows, assigned to the final
+	// centroids.
+	sample := newSilhouetteSample(vectors, cfg.SilhouetteSample, metric, newRand(cfg.Seed))
+	assignments, _ := assignClusters(vectors, centroids, cfg.Workers, distanceFor(metric))
+	run.Quality = clusterQuality(centroids, meanDistance, sample, assignments, metric)
+
+	runID, err := database.SaveClusterRun(ctx, run)
+	if err != nil {
+		return fmt.Errorf("save cluster run: %w", err)
+	}
+	labelClusters(ctx, database, runID, log)
+
+	log.Info("clusterization finished",
+		zap.Int64("run_id", runID),
+		zap.Int("rows", run.Points),
+		zap.Duration("duration", time.Since(start)),
+	)
+	return nil
+}
+
+// trainMiniBatch makes up to Epochs passes over the live rows. Each page is
+// assigned against the current centroids, then every point pulls its centroid
+// towards itself with a per-centroid learning rate of 1/count.
+func trainMiniBatch(
+	ctx context.Context,
+	database *db.Database,
+	centroids [][]float32,
+	cfg ClusterConfig,
+	log *zap.Logger,
+) ([][]float32, error) {
+	metric := db.Metric(cfg.Metric)
+	distance := distanceFor(metric)
+	counts := make([]int, len(centroids))
+	for epoch := range cfg.Epochs {
+		prev := make([][]float32, len(centroids))
+		for c := range centroids {
+			prev[c] = slices.Clone(centroids[c])
+		}
+
+		var (
+			stats   Iteration
+			afterID int64
+		)
+		for {
+			page, err := database.ClusterPage(ctx, afterID, cfg.BatchSize, false)
+			if err != nil {
+				return nil, fmt.Errorf("epoch %d: %w", epoch+1, err)
+			}
+			if len(page) == 0 {
+				break
+			}
+			afterID = page[len(page)-1].ID
+
+			vectors := pointVectors(page, metric)
+			assignments, distances := assignClusters(vectors, centroids, cfg.Workers, distance)
+			for i, vec := range vectors {
+				centroid := centroids[assignments[i]]
+				counts[assignments[i]]++
+				rate := 1 / float32(counts[assignments[i]])
+				for d := range centroid {
+					centroid[d] += rate * (vec[d] - centroid[d])
+				}
+				stats.Inertia += float64(distances[i])
+			}
+			if metric == db.MetricCosine {
+				for _, centroid := range centroids {
+					normalize(centroid)
+				}
+			}
+		}
+		stats.Shift = maxShift(prev, centroids)
+
+		// Inertia is measured against centroids that moved during the pass,
+		// so it is only an estimate.
+		log.Info("minibatch epoch",
+			zap.Int("epoch", epoch+1),
+			zap.Float64("inertia", stats.Inertia),
+			zap.Float64("shift", stats.Shift),
+		)
+		if stats.Shift <= cfg.Tolerance {
+			break
+		}
+	}
+	return centroids, nil
+}
+
+// assignAll streams every row, soft-deleted ones included, and writes its
+// nearest centroid. Like a full run, the sizes, inertia and mean member
+// distance of the run count live rows only.
+func assignAll(
+	ctx context.Context,
+	database *db.Database,
+	centroids [][]float32,
+	cfg ClusterConfig,
+	log *zap.Logger,
+) (*db.ClusterRun, []float64, error) {
+	runID, err := database.NextClusterRunID(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	metric := db.Metric(cfg.Metric)
+	run := &db.ClusterRun{
+		ID:        runID,
+		Metric:    metric,
+		Centroids: centroids,
+		Sizes:     make([]int, len(centroids)),
+		Inertia:   make([]float64, len(centroids)),
+	}
+	meanDistance := make([]float64, len(centroids))
+
+	var afterID int64
+	for {
+		page, err := database.ClusterPage(ctx, afterID, cfg.BatchSize, true)
+		if err != nil {
+			return nil, nil, fmt.Errorf("assign pass: %w", err)
+		}
+		if len(page) == 0 {
+			for c, size := range run.Sizes {
+				if size > 0 {
+					meanDistance[c] /= float64(size)
+				}
+			}
+			return run, meanDistance, nil
+		}
+		afterID = page[len(page)-1].ID
+
+		ids := make([]int64, len(page))
+		for i, point := range page {
+			ids[i] = point.ID
+		}
+		vectors := pointVectors(page, metric)
+		assignments, distances := assignClusters(vectors, centroids, cfg.Workers, distanceFor(metric))
+		if err = database.UpdateClusterIDs(ctx, runID, ids, assignments); err != nil {
+			return nil, nil, fmt.Errorf("update cluster ids up to id %d: %w", afterID, err)
+		}
+
+		for i, clusterID := range assignments {
+			if page[i].Deleted {
+				continue
+			}
+			run.Points++
+			run.Sizes[clusterID]++
+			run.Inertia[clusterID] += float64(distances[i])
+			if metric == db.MetricCosine {
+				meanDistance[clusterID] += float64(distances[i])
+			} else {
+				meanDistance[clusterID] += math.Sqrt(float64(distances[i]))
+			}
+		}
+		log.Debug("cluster ids updated", zap.Int64("to_id", afterID), zap.Int("live_rows", run.Points))
+	}
+}
+
+// pointVectors extracts the embeddings, normalised for cosine runs.
+func pointVectors(points []*db.ClusterPoint, metric db.Metric) [][]float32 {
+	vectors := make([][]float32, len(points))
+	for i, point := range points {
+		vectors[i] = point.Embedding.Slice()
+		if metric == db.MetricCosine {
+			normalize(vectors[i])
+		}
+	}
+	return vectors
+}
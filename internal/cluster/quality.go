@@ -0,0 +1,153 @@
+package cluster
+
+import (
+	"math"
+	"math/rand"
+
+	"github.com/atroxxxxxx/embed-store/internal/db"
+)
+
+// maxSilhouetteSample bounds the sample because its pairwise distance matrix
+// takes size² float32s (100 MB at this bound).
+const maxSilhouetteSample = 5000
+
+// silhouetteSample holds the pairwise distances of a fixed random subset of
+// points, so the silhouette of several clusterings of the same points can be
+// scored without recomputing them.
+type silhouetteSample struct {
+	indexes   []int
+	distances [][]float32
+}
+
+func newSilhouetteSample(vectors [][]float32, size int, metric db.Metric, rnd *rand.Rand) *silhouetteSample {
+	size = min(size, len(vectors), maxSilhouetteSample)
+	indexes := rnd.Perm(len(vectors))[:size]
+	distance := metricDistance(metric)
+
+	distances := make([][]float32, size)
+	for i := range distances {
+		distances[i] = make([]float32, size)
+	}
+	for i := range size {
+		for j := i + 1; j < size; j++ {
+			d := distance(vectors[indexes[i]], vectors[indexes[j]])
+			distances[i][j], distances[j][i] = d, d
+		}
+	}
+	return &silhouetteSample{indexes: indexes, distances: distances}
+}
+
+// scores returns the silhouette of every sample point under assignments;
+// points alone in their cluster (within the sample) score 0.
+func (obj *silhouetteSample) scores(assignments []int32, clusterCount int) []float64 {
+	out := make([]float64, len(obj.indexes))
+	sums := make([]float64, clusterCount)
+	counts := make([]int, clusterCount)
+	for i, point := range obj.indexes {
+		clear(sums)
+		clear(counts)
+		for j, other := range obj.indexes {
+			if i == j {
+				continue
+			}
+			clusterID := assignments[other]
+			sums[clusterID] += float64(obj.distances[i][j])
+			counts[clusterID]++
+		}
+
+		own := assignments[point]
+		if counts[own] == 0 {
+			continue
+		}
+		a := sums[own] / float64(counts[own])
+		b := math.Inf(1)
+		for c := range clusterCount {
+			if c != int(own) && counts[c] > 0 {
+				b = min(b, sums[c]/float64(counts[c]))
+			}
+		}
+		if math.IsInf(b, 1) || max(a, b) == 0 {
+			continue
+		}
+		out[i] = (b - a) / max(a, b)
+	}
+	return out
+}
+
+func (obj *silhouetteSample) mean(assignments []int32, clusterCount int) float64 {
+	scores := obj.scores(assignments, clusterCount)
+	if len(scores) == 0 {
+		return 0
+	}
+	sum := 0.0
+	for _, score := range scores {
+		sum += score
+	}
+	return sum / float64(len(scores))
+}
+
+// perCluster averages the sample silhouette scores by cluster; clusters with
+// no sample point score 0.
+func (obj *silhouetteSample) perCluster(assignments []int32, clusterCount int) []float64 {
+	sums := make([]float64, clusterCount)
+	counts := make([]int, clusterCount)
+	for i, score := range obj.scores(assignments, clusterCount) {
+		clusterID := assignments[obj.indexes[i]]
+		sums[clusterID] += score
+		counts[clusterID]++
+	}
+	for c := range sums {
+		if counts[c] > 0 {
+			sums[c] /= float64(counts[c])
+		}
+	}
+	return sums
+}
+
+func meanDistances(vectors [][]float32, assignments []int32, centroids [][]float32, metric db.Metric) []float64 {
+	distance := metricDistance(metric)
+	sums := make([]float64, len(centroids))
+	counts := make([]int, len(centroids))
+	for i, vec := range vectors {
+		clusterID := assignments[i]
+		sums[clusterID] += float64(distance(vec, centroids[clusterID]))
+		counts[clusterID]++
+	}
+	for c := range sums {
+		if counts[c] > 0 {
+			sums[c] /= float64(counts[c])
+		}
+	}
+	return sums
+}
+
+// clusterQuality combines the mean member distance, the nearest other
+// centroid and the sampled silhouette of every cluster. assignments must
+// cover the vectors the silhouette sample was drawn from.
+func clusterQuality(
+	centroids [][]float32,
+	meanDistance []float64,
+	sample *silhouetteSample,
+	assignments []int32,
+	metric db.Metric,
+) []*db.ClusterQuality {
+	distance := metricDistance(metric)
+	silhouette := sample.perCluster(assignments, len(centroids))
+
+	out := make([]*db.ClusterQuality, len(centroids))
+	for c := range centroids {
+		quality := &db.ClusterQuality{MeanDistance: meanDistance[c], Silhouette: silhouette[c]}
+		for other := range centroids {
+			if other == c {
+				continue
+			}
+			d := float64(distance(centroids[c], centroids[other]))
+			if quality.NearestCluster == nil || d < quality.NearestDistance {
+				nearest := int32(other)
+				quality.NearestCluster, quality.NearestDistance = &nearest, d
+			}
+		}
+		out[c] = quality
+	}
+	return out
+}
@@ -10,20 +10,9 @@ import (
 )
 
 func Run(ctx context.Context, database *db.Database, cfg ClusterConfig, log *zap.Logger) error {
-	if cfg.Clusters <= 0 {
-		cfg.Clusters = 64
-	}
-	if cfg.Iters <= 0 {
-		cfg.Iters = 10
-	}
-	if cfg.Workers <= 0 {
-		cfg.Workers = 4
-	}
-	if cfg.Limit <= 0 {
-		cfg.Limit = 20000
-	}
-	if cfg.BatchSize <= 0 {
-		cfg.BatchSize = 1000
+	metric, err := applyDefaults(&cfg)
+	if err != nil {
+		return err
 	}
 
 	log.Info("clusterization started",
@@ -32,8 +21,20 @@ func Run(ctx context.Context, database *db.Database, cfg ClusterConfig, log *zap
 		zap.Int("workers", cfg.Workers),
 		zap.Int("limit", cfg.Limit),
 		zap.Int("batch_size", cfg.BatchSize),
+		zap.Int("seed", cfg.Seed),
+		zap.Float64("tolerance", cfg.Tolerance),
+		zap.String("mode", cfg.Mode),
+		zap.String("metric", cfg.Metric),
 	)
 
+	switch cfg.Mode {
+	case ModeMiniBatch:
+		return runMiniBatch(ctx, database, cfg, log)
+	case ModeSweep:
+		_, err = Sweep(ctx, database, cfg, log)
+		return err
+	}
+
 	start := time.Now()
 	points, err := database.ClusterSource(ctx, cfg.Limit)
 	if err != nil {
@@ -51,10 +52,17 @@ func Run(ctx context.Context, database *db.Database, cfg ClusterConfig, log *zap
 		vectors[i] = point.Embedding.Slice()
 	}
 
-	assignments, err := kMeans(vectors, cfg)
+	result, err := kMeans(vectors, cfg, log)
 	if err != nil {
 		return fmt.Errorf("kmeans: %w", err)
 	}
+	assignments := result.Assignments
+	log.Info("kmeans finished", zap.Int("iterations", len(result.Iterations)))
+
+	runID, err := database.NextClusterRunID(ctx)
+	if err != nil {
+		return err
+	}
 
 	for startIdx := 0; startIdx < len(ids); startIdx += cfg.BatchSize {
 		endIdx := startIdx + cfg.BatchSize
@@ -62,16 +70,81 @@ func Run(ctx context.Context, database *db.Database, cfg ClusterConfig, log *zap
 			endIdx = len(ids)
 		}
 
-		if err := database.UpdateClusterIDs(ctx, ids[startIdx:endIdx], assignments[startIdx:endIdx]); err != nil {
+		if err := database.UpdateClusterIDs(ctx, runID, ids[startIdx:endIdx], assignments[startIdx:endIdx]); err != nil {
 			return fmt.Errorf("update cluster ids [%d:%d]: %w", startIdx, endIdx, err)
 		}
 
 		log.Debug("cluster ids updated", zap.Int("from", startIdx), zap.Int("to", endIdx))
 	}
 
+	sample := newSilhouetteSample(vectors, cfg.SilhouetteSample, metric, newRand(cfg.Seed))
+	meanDistance := meanDistances(vectors, assignments, result.Centroids, metric)
+	// Rows outside the sample keep their previous run and are reassigned by
+	// the backfill once the new centroids are current.
+	if _, err = database.SaveClusterRun(ctx, &db.ClusterRun{
+		ID:        runID,
+		Metric:    metric,
+		Points:    len(ids),
+		Centroids: result.Centroids,
+		Sizes:     result.Sizes(),
+		Inertia:   result.Inertia,
+		Quality:   clusterQuality(result.Centroids, meanDistance, sample, assignments, metric),
+	}); err != nil {
+		return fmt.Errorf("save cluster run: %w", err)
+	}
+	labelClusters(ctx, database, runID, log)
+
 	log.Info("clusterization finished",
+		zap.Int64("run_id", runID),
 		zap.Int("rows", len(ids)),
 		zap.Duration("duration", time.Since(start)),
 	)
 	return nil
 }
+
+// applyDefaults fills unset fields and validates the mode and metric.
+func applyDefaults(cfg *ClusterConfig) (db.Metric, error) {
+	if cfg.Clusters <= 0 {
+		cfg.Clusters = 64
+	}
+	if cfg.Iters <= 0 {
+		cfg.Iters = 10
+	}
+	if cfg.Workers <= 0 {
+		cfg.Workers = 4
+	}
+	if cfg.Limit <= 0 {
+		cfg.Limit = 20000
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 1000
+	}
+	if cfg.Mode == "" {
+		cfg.Mode = ModeFull
+	}
+	if cfg.Epochs <= 0 {
+		cfg.Epochs = 3
+	}
+	if cfg.SweepMin <= 0 {
+		cfg.SweepMin = 8
+	}
+	if cfg.SweepMax < cfg.SweepMin {
+		cfg.SweepMax = max(cfg.SweepMin, 128)
+	}
+	if cfg.SweepStep <= 0 {
+		cfg.SweepStep = 8
+	}
+	if cfg.SilhouetteSample <= 0 {
+		cfg.SilhouetteSample = 1000
+	}
+	cfg.SilhouetteSample = min(cfg.SilhouetteSample, maxSilhouetteSample)
+	if cfg.Mode != ModeFull && cfg.Mode != ModeMiniBatch && cfg.Mode != ModeSweep {
+		return "", ErrUnknownMode
+	}
+	metric, err := db.ParseMetric(cfg.Metric)
+	if err != nil || metric == db.MetricInnerProduct {
+		return "", ErrUnsupportedMetric
+	}
+	cfg.Metric = string(metric)
+	return metric, nil
+}
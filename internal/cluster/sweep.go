@@ -0,0 +1,80 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/atroxxxxxx/embed-store/internal/db"
+	"go.uber.org/zap"
+)
+
+// Sweep runs k-means on one ClusterSource sample for every k in
+// [SweepMin, SweepMax] by SweepStep, scores each with inertia (for the elbow)
+// and a sampled silhouette, recommends the k with the best silhouette and
+// stores the report. Cluster ids are not touched.
+func Sweep(ctx context.Context, database *db.Database, cfg ClusterConfig, log *zap.Logger) (*db.ClusterEvaluation, error) {
+	metric, err := applyDefaults(&cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	points, err := database.ClusterSource(ctx, cfg.Limit)
+	if err != nil {
+		return nil, fmt.Errorf("cluster source: %w", err)
+	}
+	if len(points) == 0 {
+		return nil, ErrEmptyDataset
+	}
+	if cfg.SweepMin > len(points) {
+		// No k would be tried; do not store an empty report.
+		return nil, fmt.Errorf("%w: %d > %d", ErrSweepRange, cfg.SweepMin, len(points))
+	}
+	vectors := pointVectors(points, metric)
+	sample := newSilhouetteSample(vectors, cfg.SilhouetteSample, metric, newRand(cfg.Seed))
+
+	report := &db.ClusterEvaluation{
+		Metric: metric,
+		Points: len(vectors),
+		Sample: len(sample.indexes),
+	}
+	bestSilhouette := -2.0
+	for k := cfg.SweepMin; k <= min(cfg.SweepMax, len(vectors)); k += cfg.SweepStep {
+		if err = ctx.Err(); err != nil {
+			return nil, err
+		}
+		kCfg := cfg
+		kCfg.Clusters = k
+		result, err := kMeans(vectors, kCfg, zap.NewNop())
+		if err != nil {
+			return nil, fmt.Errorf("kmeans k=%d: %w", k, err)
+		}
+
+		score := &db.ClusterScore{K: k, Silhouette: sample.mean(result.Assignments, len(result.Centroids))}
+		for _, inertia := range result.Inertia {
+			score.Inertia += inertia
+		}
+		report.Scores = append(report.Scores, score)
+		if score.Silhouette > bestSilhouette {
+			bestSilhouette, report.RecommendedK = score.Silhouette, k
+		}
+
+		log.Info("cluster sweep",
+			zap.Int("k", k),
+			zap.Float64("inertia", score.Inertia),
+			zap.Float64("silhouette", score.Silhouette),
+			zap.Int("iterations", len(result.Iterations)),
+		)
+	}
+
+	if report.ID, err = database.SaveClusterEvaluation(ctx, report); err != nil {
+		return nil, fmt.Errorf("save cluster evaluation: %w", err)
+	}
+	log.Info("cluster sweep finished",
+		zap.Int64("evaluation_id", report.ID),
+		zap.Int("recommended_k", report.RecommendedK),
+		zap.Duration("duration", time.Since(start)),
+	)
+	return report, nil
+}
@@ -21,7 +21,9 @@ type Chunk struct {
 	Dead      bool
 	Embedding pgvector.Vector
 	ClusterID *int32
-	Info      Metadata
+	// ClusterRunID is the clustering run whose centroids ClusterID refers to.
+	ClusterRunID *int64
+	Info         Metadata
 }
 
 type Metadata struct {
@@ -2,8 +2,11 @@ package db
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/pgvector/pgvector-go"
 )
@@ -11,8 +14,13 @@ import (
 type ClusterPoint struct {
 	ID        int64
 	Embedding pgvector.Vector
+	// Deleted is only filled by ClusterPage.
+	Deleted bool
 }
 
+// ClusterSource returns a uniform random sample of up to limit live rows
+// rather than the physically first ones, which would bias seeding towards the
+// oldest rows.
 func (obj *Database) ClusterSource(ctx context.Context, limit int) ([]*ClusterPoint, error) {
 	if limit <= 0 {
 		limit = 10000
@@ -21,6 +29,7 @@ func (obj *Database) ClusterSource(ctx context.Context, limit int) ([]*ClusterPo
 	SELECT id, embedding
 	FROM hackernews
 	WHERE deleted = false
+	ORDER BY random()
 	LIMIT $1
 `
 
@@ -45,7 +54,9 @@ func (obj *Database) ClusterSource(ctx context.Context, limit int) ([]*ClusterPo
 	return out, nil
 }
 
-func (obj *Database) UpdateClusterIDs(ctx context.Context, ids []int64, clusterIDs []int32) error {
+// UpdateClusterIDs writes cluster ids assigned against the centroids of run
+// runID.
+func (obj *Database) UpdateClusterIDs(ctx context.Context, runID int64, ids []int64, clusterIDs []int32) error {
 	lenIDs := len(ids)
 	if lenIDs == 0 {
 		return nil
@@ -60,11 +71,12 @@ func (obj *Database) UpdateClusterIDs(ctx context.Context, ids []int64, clusterI
 
 	builder.WriteString(`
 	UPDATE hackernews AS h
-	SET cluster_id = v.cluster_id
+	SET cluster_id = v.cluster_id, cluster_run_id = $1
 	FROM (VALUES `)
 
-	args := make([]any, 0, lenIDs*2)
-	argNum := 1
+	args := make([]any, 0, 1+lenIDs*2)
+	args = append(args, runID)
+	argNum := 2
 	for i := range lenIDs {
 		if i > 0 {
 			builder.WriteByte(',')
@@ -82,3 +94,341 @@ func (obj *Database) UpdateClusterIDs(ctx context.Context, ids []int64, clusterI
 	}
 	return nil
 }
+
+type ClusterRun struct {
+	// ID is reserved with NextClusterRunID before the run writes cluster ids.
+	ID        int64
+	Metric    Metric
+	Points    int
+	Centroids [][]float32
+	Sizes     []int
+	Inertia   []float64
+	// Quality is optional; when set it has one entry per centroid.
+	Quality []*ClusterQuality
+}
+
+// ClusterQuality distances are in the run metric: Euclidean for l2 and cosine
+// distance for cosine runs.
+type ClusterQuality struct {
+	MeanDistance    float64
+	NearestCluster  *int32
+	NearestDistance float64
+	Silhouette      float64
+}
+
+type ClusterInfo struct {
+	ID      int32
+	RunID   int64
+	Metric  Metric
+	Size    int
+	Inertia float64
+	ClusterQuality
+	Label     string
+	Centroid  pgvector.Vector
+	UpdatedAt time.Time
+	Samples   []*ClusterSample
+}
+
+type ClusterSample struct {
+	ID   int64
+	Text string
+}
+
+// NextClusterRunID reserves the id of a new cluster run. Rows written with it
+// are ahead of the current run until SaveClusterRun, so the backfill leaves
+// them alone; after a failed run they are reassigned once a later run is saved.
+func (obj *Database) NextClusterRunID(ctx context.Context) (int64, error) {
+	var id int64
+	row := obj.DB.QueryRowContext(ctx, "SELECT nextval(pg_get_serial_sequence('cluster_runs', 'id'))")
+	if err := row.Scan(&id); err != nil {
+		return 0, fmt.Errorf("next cluster run id: %w", err)
+	}
+	return id, nil
+}
+
+// SaveClusterRun records the run with its centroids and makes them the
+// current ones; centroid i gets cluster id i, matching the ids written by
+// UpdateClusterIDs. Rows still carrying an older run are left to the backfill.
+func (obj *Database) SaveClusterRun(ctx context.Context, run *ClusterRun) (int64, error) {
+	count := len(run.Centroids)
+	if len(run.Sizes) != count || len(run.Inertia) != count || (run.Quality != nil && len(run.Quality) != count) {
+		return 0, fmt.Errorf("centroids len %d != sizes len %d, inertia len %d or quality len %d",
+			count, len(run.Sizes), len(run.Inertia), len(run.Quality))
+	}
+	total := 0.0
+	for _, inertia := range run.Inertia {
+		total += inertia
+	}
+
+	tx, err := obj.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("save cluster run begin: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	runID := run.ID
+	if _, err = tx.ExecContext(ctx,
+		"INSERT INTO cluster_runs (id, clusters, points, inertia, metric) VALUES ($1, $2, $3, $4, $5)",
+		runID, count, run.Points, total, run.Metric); err != nil {
+		return 0, fmt.Errorf("insert cluster run: %w", err)
+	}
+	if _, err = tx.ExecContext(ctx, "DELETE FROM clusters"); err != nil {
+		return 0, fmt.Errorf("clear centroids: %w", err)
+	}
+
+	const (
+		qualityColumns = "mean_distance, nearest_cluster, nearest_distance, silhouette"
+		historyRequest = "INSERT INTO cluster_run_centroids (run_id, cluster_id, centroid, size, inertia, " +
+			qualityColumns + ") VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)"
+		currentRequest = "INSERT INTO clusters (run_id, id, centroid, size, inertia, " +
+			qualityColumns + ") VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)"
+	)
+	for idx, centroid := range run.Centroids {
+		vec := pgvector.NewVector(centroid)
+		quality := &ClusterQuality{}
+		if run.Quality != nil {
+			quality = run.Quality[idx]
+		}
+		for _, request := range []string{historyRequest, currentRequest} {
+			if _, err = tx.ExecContext(ctx, request, runID, idx, vec, run.Sizes[idx], run.Inertia[idx],
+				quality.MeanDistance, quality.NearestCluster, quality.NearestDistance, quality.Silhouette); err != nil {
+				return 0, fmt.Errorf("insert centroid %d: %w", idx, err)
+			}
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return 0, fmt.Errorf("save cluster run commit: %w", err)
+	}
+	return runID, nil
+}
+
+// ListClusters returns the current clusters with up to samples live chunks
+// each, lowest ids first.
+func (obj *Database) ListClusters(ctx context.Context, samples int) ([]*ClusterInfo, error) {
+	const request = `
+	SELECT c.id, coalesce(c.run_id, 0), coalesce(r.metric, 'l2'), c.size, c.inertia, c.mean_distance,
+		c.nearest_cluster, c.nearest_distance, c.silhouette, c.label, c.updated_at, s.id, s.text
+	FROM clusters c
+	LEFT JOIN cluster_runs r ON r.id = c.run_id
+	LEFT JOIN LATERAL (
+		SELECT h.id, h.text
+		FROM hackernews h
+		WHERE h.cluster_id = c.id AND h.cluster_run_id = c.run_id AND NOT h.deleted
+		ORDER BY h.id
+		LIMIT $1
+	) s ON true
+	ORDER BY c.id, s.id
+`
+	rows, err := obj.DB.QueryContext(ctx, request, samples)
+	if err != nil {
+		return nil, fmt.Errorf("list clusters: %w", err)
+	}
+	defer rows.Close()
+
+	var out []*ClusterInfo
+	for rows.Next() {
+		var (
+			info       ClusterInfo
+			sampleID   sql.NullInt64
+			sampleText sql.NullString
+		)
+		if err = rows.Scan(&info.ID, &info.RunID, &info.Metric, &info.Size, &info.Inertia, &info.MeanDistance,
+			&info.NearestCluster, &info.NearestDistance, &info.Silhouette, &info.Label, &info.UpdatedAt,
+			&sampleID, &sampleText); err != nil {
+			return nil, fmt.Errorf("list clusters scan: %w", err)
+		}
+		if len(out) == 0 || out[len(out)-1].ID != info.ID {
+			out = append(out, &info)
+		}
+		if sampleID.Valid {
+			current := out[len(out)-1]
+			current.Samples = append(current.Samples, &ClusterSample{ID: sampleID.Int64, Text: sampleText.String})
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("list clusters rows: %w", err)
+	}
+	return out, nil
+}
+
+func (obj *Database) ClusterByID(ctx context.Context, id int32) (*ClusterInfo, error) {
+	const request = `
+	SELECT c.id, coalesce(c.run_id, 0), coalesce(r.metric, 'l2'), c.size, c.inertia, c.mean_distance,
+		c.nearest_cluster, c.nearest_distance, c.silhouette, c.label, c.updated_at, c.centroid
+	FROM clusters c
+	LEFT JOIN cluster_runs r ON r.id = c.run_id
+	WHERE c.id = $1
+`
+	var info ClusterInfo
+	row := obj.DB.QueryRowContext(ctx, request, id)
+	if err := row.Scan(&info.ID, &info.RunID, &info.Metric, &info.Size, &info.Inertia, &info.MeanDistance,
+		&info.NearestCluster, &info.NearestDistance, &info.Silhouette, &info.Label, &info.UpdatedAt,
+		&info.Centroid); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("cluster by id: %w", err)
+	}
+	return &info, nil
+}
+
+// LoadCentroids returns the current run id (0 when there are no clusters)
+// and its centroids ordered by cluster id.
+func (obj *Database) LoadCentroids(ctx context.Context) (int64, []int32, [][]float32, error) {
+	rows, err := obj.DB.QueryContext(ctx, "SELECT coalesce(run_id, 0), id, centroid FROM clusters ORDER BY id")
+	if err != nil {
+		return 0, nil, nil, fmt.Errorf("load centroids: %w", err)
+	}
+	defer rows.Close()
+
+	var (
+		runID     int64
+		ids       []int32
+		centroids [][]float32
+	)
+	for rows.Next() {
+		var (
+			id       int32
+			centroid pgvector.Vector
+		)
+		if err = rows.Scan(&runID, &id, &centroid); err != nil {
+			return 0, nil, nil, fmt.Errorf("load centroids scan: %w", err)
+		}
+		ids = append(ids, id)
+		centroids = append(centroids, centroid.Slice())
+	}
+	if err = rows.Err(); err != nil {
+		return 0, nil, nil, fmt.Errorf("load centroids rows: %w", err)
+	}
+	return runID, ids, centroids, nil
+}
+
+// UnclusteredPoints pages in id order through rows that have no cluster_id or
+// one assigned against a run older than runID.
+func (obj *Database) UnclusteredPoints(ctx context.Context, runID, afterID int64, limit int) ([]*ClusterPoint, error) {
+	const request = `
+	SELECT id, embedding
+	FROM hackernews
+	WHERE (cluster_run_id IS NULL OR cluster_run_id < $1) AND id > $2
+	ORDER BY id
+	LIMIT $3
+`
+	rows, err := obj.DB.QueryContext(ctx, request, runID, afterID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("unclustered points: %w", err)
+	}
+	defer rows.Close()
+
+	out := make([]*ClusterPoint, 0, limit)
+	for rows.Next() {
+		var point ClusterPoint
+		if err = rows.Scan(&point.ID, &point.Embedding); err != nil {
+			return nil, fmt.Errorf("unclustered points scan: %w", err)
+		}
+		out = append(out, &point)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("unclustered points rows: %w", err)
+	}
+	return out, nil
+}
+
+// ClusterPage pages through the table in id order with bounded memory;
+// soft-deleted rows are skipped unless includeDeleted is set.
+func (obj *Database) ClusterPage(
+	ctx context.Context,
+	afterID int64,
+	limit int,
+	includeDeleted bool,
+) ([]*ClusterPoint, error) {
+	const request = `
+	SELECT id, embedding, deleted
+	FROM hackernews
+	WHERE id > $1 AND ($3 OR NOT deleted)
+	ORDER BY id
+	LIMIT $2
+`
+	rows, err := obj.DB.QueryContext(ctx, request, afterID, limit, includeDeleted)
+	if err != nil {
+		return nil, fmt.Errorf("cluster page: %w", err)
+	}
+	defer rows.Close()
+
+	out := make([]*ClusterPoint, 0, limit)
+	for rows.Next() {
+		var point ClusterPoint
+		if err = rows.Scan(&point.ID, &point.Embedding, &point.Deleted); err != nil {
+			return nil, fmt.Errorf("cluster page scan: %w", err)
+		}
+		out = append(out, &point)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("cluster page rows: %w", err)
+	}
+	return out, nil
+}
+
+type ClusterText struct {
+	ClusterID int32
+	Title     string
+	Text      string
+}
+
+// ClusterTexts returns up to perCluster live chunks of every current cluster.
+func (obj *Database) ClusterTexts(ctx context.Context, perCluster int) ([]*ClusterText, error) {
+	const request = `
+	SELECT c.id, s.title, s.text
+	FROM clusters c
+	JOIN LATERAL (
+		SELECT coalesce(h.title, '') AS title, h.text
+		FROM hackernews h
+		WHERE h.cluster_id = c.id AND h.cluster_run_id = c.run_id AND NOT h.deleted
+		ORDER BY h.id
+		LIMIT $1
+	) s ON true
+`
+	rows, err := obj.DB.QueryContext(ctx, request, perCluster)
+	if err != nil {
+		return nil, fmt.Errorf("cluster texts: %w", err)
+	}
+	defer rows.Close()
+
+	var out []*ClusterText
+	for rows.Next() {
+		var text ClusterText
+		if err = rows.Scan(&text.ClusterID, &text.Title, &text.Text); err != nil {
+			return nil, fmt.Errorf("cluster texts scan: %w", err)
+		}
+		out = append(out, &text)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("cluster texts rows: %w", err)
+	}
+	return out, nil
+}
+
+// SetClusterLabels stores labels on the current clusters and on the run
+// history.
+func (obj *Database) SetClusterLabels(ctx context.Context, runID int64, labels map[int32]string) error {
+	tx, err := obj.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("set labels begin: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	for clusterID, label := range labels {
+		if _, err = tx.ExecContext(ctx, "UPDATE clusters SET label = $2 WHERE id = $1 AND run_id = $3",
+			clusterID, label, runID); err != nil {
+			return fmt.Errorf("set label %d: %w", clusterID, err)
+		}
+		if _, err = tx.ExecContext(ctx,
+			"UPDATE cluster_run_centroids SET label = $2 WHERE cluster_id = $1 AND run_id = $3",
+			clusterID, label, runID); err != nil {
+			return fmt.Errorf("set run label %d: %w", clusterID, err)
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("set labels commit: %w", err)
+	}
+	return nil
+}
@@ -21,6 +21,7 @@ const (
 var (
 	ErrChunkNil     = errors.New("chunk is null")
 	ErrDuplicateKey = errors.New("duplicate key")
+	ErrNotFound     = errors.New("not found")
 )
 
 func Connect(dsn string, ctx context.Context) (Database, error) {
@@ -0,0 +1,94 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+type ClusterEvaluation struct {
+	ID           int64
+	CreatedAt    time.Time
+	Metric       Metric
+	Points       int
+	Sample       int
+	RecommendedK int
+	Scores       []*ClusterScore
+}
+
+type ClusterScore struct {
+	K          int
+	Inertia    float64
+	Silhouette float64
+}
+
+func (obj *Database) SaveClusterEvaluation(ctx context.Context, evaluation *ClusterEvaluation) (int64, error) {
+	tx, err := obj.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("save evaluation begin: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var id int64
+	row := tx.QueryRowContext(ctx,
+		"INSERT INTO cluster_evaluations (metric, points, sample, recommended_k) VALUES ($1, $2, $3, $4) "+
+			"RETURNING id, created_at",
+		evaluation.Metric, evaluation.Points, evaluation.Sample, evaluation.RecommendedK)
+	if err = row.Scan(&id, &evaluation.CreatedAt); err != nil {
+		return 0, fmt.Errorf("insert evaluation: %w", err)
+	}
+
+	const request = "INSERT INTO cluster_evaluation_scores (evaluation_id, k, inertia, silhouette) " +
+		"VALUES ($1, $2, $3, $4)"
+	for _, score := range evaluation.Scores {
+		if _, err = tx.ExecContext(ctx, request, id, score.K, score.Inertia, score.Silhouette); err != nil {
+			return 0, fmt.Errorf("insert evaluation score k=%d: %w", score.K, err)
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return 0, fmt.Errorf("save evaluation commit: %w", err)
+	}
+	return id, nil
+}
+
+// LatestClusterEvaluation returns the most recent sweep report with its
+// scores ordered by k.
+func (obj *Database) LatestClusterEvaluation(ctx context.Context) (*ClusterEvaluation, error) {
+	const request = `
+	SELECT id, created_at, metric, points, sample, recommended_k
+	FROM cluster_evaluations
+	ORDER BY id DESC
+	LIMIT 1
+`
+	var evaluation ClusterEvaluation
+	row := obj.DB.QueryRowContext(ctx, request)
+	if err := row.Scan(&evaluation.ID, &evaluation.CreatedAt, &evaluation.Metric, &evaluation.Points,
+		&evaluation.Sample, &evaluation.RecommendedK); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("latest evaluation: %w", err)
+	}
+
+	rows, err := obj.DB.QueryContext(ctx,
+		"SELECT k, inertia, silhouette FROM cluster_evaluation_scores WHERE evaluation_id = $1 ORDER BY k",
+		evaluation.ID)
+	if err != nil {
+		return nil, fmt.Errorf("evaluation scores: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var score ClusterScore
+		if err = rows.Scan(&score.K, &score.Inertia, &score.Silhouette); err != nil {
+			return nil, fmt.Errorf("evaluation scores scan: %w", err)
+		}
+		evaluation.Scores = append(evaluation.Scores, &score)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("evaluation scores rows: %w", err)
+	}
+	return &evaluation, nil
+}
@@ -0,0 +1,90 @@
+package db
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Filter struct {
+	Types         []string
+	Authors       []string
+	From          *time.Time
+	To            *time.Time
+	MinScore      *int32
+	DocIDs        []int64
+	ClusterIDs    []int32
+	ClusterRunID  *int64
+	Deleted       *bool
+	Dead          *bool
+	ExcludeIDs    []int64
+	ExcludeDocIDs []int64
+	// IncludeDeleted disables the default exclusion of soft-deleted rows
+	// when Deleted is not set explicitly.
+	IncludeDeleted bool
+}
+
+type queryBuilder struct {
+	conditions []string
+	args       []any
+}
+
+func (obj *queryBuilder) arg(value any) string {
+	obj.args = append(obj.args, value)
+	return "$" + strconv.Itoa(len(obj.args))
+}
+
+func (obj *queryBuilder) where(condition string) {
+	obj.conditions = append(obj.conditions, condition)
+}
+
+func (obj *queryBuilder) whereClause() string {
+	if len(obj.conditions) == 0 {
+		return ""
+	}
+	return "WHERE " + strings.Join(obj.conditions, " AND ")
+}
+
+func (obj *queryBuilder) applyFilter(filter *Filter) {
+	if filter == nil {
+		return
+	}
+	if len(filter.Types) > 0 {
+		obj.where("type = ANY(" + obj.arg(filter.Types) + ")")
+	}
+	if len(filter.Authors) > 0 {
+		obj.where("author = ANY(" + obj.arg(filter.Authors) + ")")
+	}
+	if filter.From != nil {
+		obj.where("time >= " + obj.arg(*filter.From))
+	}
+	if filter.To != nil {
+		obj.where("time <= " + obj.arg(*filter.To))
+	}
+	if filter.MinScore != nil {
+		obj.where("score >= " + obj.arg(*filter.MinScore))
+	}
+	if len(filter.DocIDs) > 0 {
+		obj.where("doc_id = ANY(" + obj.arg(filter.DocIDs) + ")")
+	}
+	if len(filter.ClusterIDs) > 0 {
+		obj.where("cluster_id = ANY(" + obj.arg(filter.ClusterIDs) + ")")
+	}
+	if filter.ClusterRunID != nil {
+		obj.where("cluster_run_id = " + obj.arg(*filter.ClusterRunID))
+	}
+	if filter.Deleted != nil {
+		obj.where("deleted = " + obj.arg(*filter.Deleted))
+	} else if !filter.IncludeDeleted {
+		obj.where("NOT deleted")
+	}
+	if filter.Dead != nil {
+		obj.where("dead = " + obj.arg(*filter.Dead))
+	}
+	if len(filter.ExcludeIDs) > 0 {
+		obj.where("id <> ALL(" + obj.arg(filter.ExcludeIDs) + ")")
+	}
+	if len(filter.ExcludeDocIDs) > 0 {
+		obj.where("doc_id <> ALL(" + obj.arg(filter.ExcludeDocIDs) + ")")
+	}
+}
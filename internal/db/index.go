@@ -0,0 +1,194 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	IndexHNSW    = "hnsw"
+	IndexIVFFlat = "ivfflat"
+)
+
+var (
+	ErrInvalidIndexName   = errors.New("invalid index name")
+	ErrInvalidIndexMethod = errors.New("index method must be hnsw or ivfflat")
+	ErrInvalidIndexParams = errors.New("invalid index build parameters")
+
+	indexNamePattern = regexp.MustCompile(`^[a-z_][a-z0-9_]{0,62}$`)
+)
+
+type IndexInfo struct {
+	Name       string
+	Method     string
+	OpClass    string
+	Options    []string
+	SizeBytes  int64
+	Valid      bool
+	Definition string
+}
+
+type IndexSpec struct {
+	Name           string
+	Method         string
+	Metric         Metric
+	Lists          int
+	M              int
+	EfConstruction int
+}
+
+type IndexProgress struct {
+	PID         int64
+	Index       string
+	Phase       string
+	BlocksDone  int64
+	BlocksTotal int64
+	TuplesDone  int64
+	TuplesTotal int64
+}
+
+// Validate fills pgvector defaults and rejects values that cannot be
+// interpolated into DDL safely.
+func (obj *IndexSpec) Validate() error {
+	if !indexNamePattern.MatchString(obj.Name) {
+		return ErrInvalidIndexName
+	}
+	switch obj.Method {
+	case IndexHNSW:
+		if obj.M == 0 {
+			obj.M = 16
+		}
+		if obj.EfConstruction == 0 {
+			obj.EfConstruction = 64
+		}
+		if obj.M < 2 || obj.M > 100 || obj.EfConstruction < 4 || obj.EfConstruction > 1000 ||
+			obj.EfConstruction < 2*obj.M || obj.Lists != 0 {
+			return ErrInvalidIndexParams
+		}
+	case IndexIVFFlat:
+		if obj.Lists == 0 {
+			obj.Lists = 100
+		}
+		if obj.Lists < 1 || obj.Lists > 32768 || obj.M != 0 || obj.EfConstruction != 0 {
+			return ErrInvalidIndexParams
+		}
+	default:
+		return ErrInvalidIndexMethod
+	}
+	return nil
+}
+
+func (obj *Database) ListIndexes(ctx context.Context) ([]*IndexInfo, error) {
+	const request = `
+	SELECT
+		i.relname, am.amname, coalesce(opc.opcname, ''),
+		array_to_string(coalesce(i.reloptions, '{}'), ','),
+		pg_relation_size(i.oid), ix.indisvalid, pg_get_indexdef(i.oid)
+	FROM pg_index ix
+	JOIN pg_class i ON i.oid = ix.indexrelid
+	JOIN pg_class t ON t.oid = ix.indrelid
+	JOIN pg_am am ON am.oid = i.relam
+	LEFT JOIN pg_opclass opc ON opc.oid = ix.indclass[0]
+	WHERE t.relname = 'hackernews' AND am.amname IN ('hnsw', 'ivfflat')
+	ORDER BY i.relname
+`
+	rows, err := obj.DB.QueryContext(ctx, request)
+	if err != nil {
+		return nil, fmt.Errorf("list indexes: %w", err)
+	}
+	defer rows.Close()
+
+	var out []*IndexInfo
+	for rows.Next() {
+		var (
+			info    IndexInfo
+			options string
+		)
+		if err = rows.Scan(&info.Name, &info.Method, &info.OpClass, &options,
+			&info.SizeBytes, &info.Valid, &info.Definition); err != nil {
+			return nil, fmt.Errorf("list indexes scan: %w", err)
+		}
+		if options != "" {
+			info.Options = strings.Split(options, ",")
+		}
+		out = append(out, &info)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("list indexes rows: %w", err)
+	}
+	return out, nil
+}
+
+// CreateIndex builds the index with CREATE INDEX CONCURRENTLY, so it blocks
+// until the build ends; use IndexProgress from another connection to watch it.
+func (obj *Database) CreateIndex(ctx context.Context, spec IndexSpec) error {
+	if err := spec.Validate(); err != nil {
+		return err
+	}
+
+	params := fmt.Sprintf("lists = %d", spec.Lists)
+	if spec.Method == IndexHNSW {
+		params = fmt.Sprintf("m = %d, ef_construction = %d", spec.M, spec.EfConstruction)
+	}
+	request := fmt.Sprintf("CREATE INDEX CONCURRENTLY %s ON hackernews USING %s (embedding %s) WITH (%s)",
+		spec.Name, spec.Method, spec.Metric.OpClass(), params)
+
+	if _, err := obj.DB.ExecContext(ctx, request); err != nil {
+		return fmt.Errorf("create index %s: %w", spec.Name, err)
+	}
+	return nil
+}
+
+// DropIndex only drops vector indexes of the hackernews table.
+func (obj *Database) DropIndex(ctx context.Context, name string) error {
+	if !indexNamePattern.MatchString(name) {
+		return ErrInvalidIndexName
+	}
+	indexes, err := obj.ListIndexes(ctx)
+	if err != nil {
+		return err
+	}
+	found := false
+	for _, index := range indexes {
+		found = found || index.Name == name
+	}
+	if !found {
+		return ErrNotFound
+	}
+
+	if _, err = obj.DB.ExecContext(ctx, "DROP INDEX CONCURRENTLY IF EXISTS "+name); err != nil {
+		return fmt.Errorf("drop index %s: %w", name, err)
+	}
+	return nil
+}
+
+func (obj *Database) IndexProgress(ctx context.Context) ([]*IndexProgress, error) {
+	const request = `
+	SELECT p.pid, coalesce(c.relname, ''), p.phase, p.blocks_done, p.blocks_total, p.tuples_done, p.tuples_total
+	FROM pg_stat_progress_create_index p
+	LEFT JOIN pg_class c ON c.oid = p.index_relid
+	WHERE p.relid = 'hackernews'::regclass
+`
+	rows, err := obj.DB.QueryContext(ctx, request)
+	if err != nil {
+		return nil, fmt.Errorf("index progress: %w", err)
+	}
+	defer rows.Close()
+
+	var out []*IndexProgress
+	for rows.Next() {
+		var progress IndexProgress
+		if err = rows.Scan(&progress.PID, &progress.Index, &progress.Phase, &progress.BlocksDone,
+			&progress.BlocksTotal, &progress.TuplesDone, &progress.TuplesTotal); err != nil {
+			return nil, fmt.Errorf("index progress scan: %w", err)
+		}
+		out = append(out, &progress)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("index progress rows: %w", err)
+	}
+	return out, nil
+}
@@ -0,0 +1,65 @@
+package db
+
+import (
+	"errors"
+	"strings"
+)
+
+type Metric string
+
+const (
+	MetricL2           Metric = "l2"
+	MetricCosine       Metric = "cosine"
+	MetricInnerProduct Metric = "inner_product"
+)
+
+var ErrUnknownMetric = errors.New("unknown metric")
+
+func ParseMetric(value string) (Metric, error) {
+	switch Metric(strings.TrimSpace(strings.ToLower(value))) {
+	case "", MetricL2:
+		return MetricL2, nil
+	case MetricCosine:
+		return MetricCosine, nil
+	case MetricInnerProduct:
+		return MetricInnerProduct, nil
+	default:
+		return "", ErrUnknownMetric
+	}
+}
+
+func (obj Metric) Operator() string {
+	switch obj {
+	case MetricCosine:
+		return "<=>"
+	case MetricInnerProduct:
+		return "<#>"
+	default:
+		return "<->"
+	}
+}
+
+// Similarity converts a raw operator value into a similarity score.
+// pgvector returns 1 - cos for <=> and the negated inner product for <#>;
+// L2 has no bounded similarity, so ok is false for it.
+func (obj Metric) Similarity(distance float64) (similarity float64, ok bool) {
+	switch obj {
+	case MetricCosine:
+		return 1 - distance, true
+	case MetricInnerProduct:
+		return -distance, true
+	default:
+		return 0, false
+	}
+}
+
+func (obj Metric) OpClass() string {
+	switch obj {
+	case MetricCosine:
+		return "vector_cosine_ops"
+	case MetricInnerProduct:
+		return "vector_ip_ops"
+	default:
+		return "vector_l2_ops"
+	}
+}
@@ -2,9 +2,11 @@ package db
 
 import (
 	"context"
+	"database/sql"
 	"errors"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/pgvector/pgvector-go"
@@ -12,15 +14,16 @@ import (
 
 func (obj *Database) InsertChunk(ctx context.Context, chunk *Chunk) (int64, error) {
 	const request = "INSERT INTO hackernews " +
-		"(doc_id, title, author, text, time, type, score, deleted, dead, embedding, chunk_no, chunk_start, chunk_end) " +
-		"VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13) RETURNING id"
+		"(doc_id, title, author, text, time, type, score, deleted, dead, embedding, chunk_no, chunk_start, chunk_end, " +
+		"cluster_id, cluster_run_id) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15) " +
+		"RETURNING id"
 	if chunk == nil {
 		return 0, ErrChunkNil
 	}
 
 	row := obj.DB.QueryRowContext(ctx, request,
 		chunk.DocID, chunk.Title, chunk.Author, chunk.Text, chunk.Time, chunk.Type, chunk.Score, chunk.Deleted, chunk.Dead,
-		chunk.Embedding, chunk.Info.Number, chunk.Info.Start, chunk.Info.End)
+		chunk.Embedding, chunk.Info.Number, chunk.Info.Start, chunk.Info.End, chunk.ClusterID, chunk.ClusterRunID)
 	if err := row.Scan(&chunk.ID); err != nil {
 		var pgErr *pgconn.PgError
 		if errors.As(err, &pgErr) {
@@ -33,18 +36,25 @@ func (obj *Database) InsertChunk(ctx context.Context, chunk *Chunk) (int64, erro
 	return chunk.ID, nil
 }
 
+type chunkKey struct {
+	docID  int64
+	number int32
+}
+
+// InsertBatch skips rows that conflict on (doc_id, chunk_no) and sets ID only
+// on the chunks that were actually inserted.
 func (obj *Database) InsertBatch(ctx context.Context, batch []*Chunk) (int64, error) {
 	if len(batch) == 0 {
 		return 0, nil
 	}
 
-	const columnsPerRow = 13
+	const columnsPerRow = 15
 	var queryBuilder strings.Builder
 	queryBuilder.Grow(256 + len(batch)*columnsPerRow*6)
 	queryBuilder.WriteString(`
 	INSERT INTO hackernews (
 		doc_id, title, author, text, time, type, score, deleted, dead, embedding, 
-		chunk_no, chunk_start, chunk_end
+		chunk_no, chunk_start, chunk_end, cluster_id, cluster_run_id
 	 ) VALUES 
 `)
 
@@ -83,19 +93,42 @@ func (obj *Database) InsertBatch(ctx context.Context, batch []*Chunk) (int64, er
 			chunk.Info.Number,
 			chunk.Info.Start,
 			chunk.Info.End,
+			chunk.ClusterID,
+			chunk.ClusterRunID,
 		)
 	}
 
-	queryBuilder.WriteString(" ON CONFLICT (doc_id, chunk_no) DO NOTHING")
+	queryBuilder.WriteString(" ON CONFLICT (doc_id, chunk_no) DO NOTHING RETURNING id, doc_id, chunk_no")
 
-	result, err := obj.DB.ExecContext(ctx, queryBuilder.String(), args...)
+	rows, err := obj.DB.QueryContext(ctx, queryBuilder.String(), args...)
 	if err != nil {
 		return 0, fmt.Errorf("batch insert failed: %w", err)
 	}
+	defer rows.Close()
+
+	pending := make(map[chunkKey][]*Chunk, len(batch))
+	for _, chunk := range batch {
+		key := chunkKey{docID: chunk.DocID, number: chunk.Info.Number}
+		pending[key] = append(pending[key], chunk)
+	}
 
-	affected, err := result.RowsAffected()
-	if err != nil {
-		return 0, fmt.Errorf("rows affected: %w", err)
+	var affected int64
+	for rows.Next() {
+		var (
+			id  int64
+			key chunkKey
+		)
+		if err = rows.Scan(&id, &key.docID, &key.number); err != nil {
+			return affected, fmt.Errorf("batch insert scan: %w", err)
+		}
+		if chunks := pending[key]; len(chunks) > 0 {
+			chunks[0].ID = id
+			pending[key] = chunks[1:]
+		}
+		affected++
+	}
+	if err = rows.Err(); err != nil {
+		return affected, fmt.Errorf("batch insert rows: %w", err)
 	}
 
 	return affected, nil
@@ -109,86 +142,138 @@ func (obj *Database) ChunkByID(ctx context.Context, id int64) (*Chunk, error) {
 	if err := row.Scan(&chunk.DocID, &chunk.Title, &chunk.Author, &chunk.Text,
 		&chunk.Time, &chunk.Type, &chunk.Score, &chunk.Deleted, &chunk.Dead, &chunk.Embedding,
 		&chunk.Info.Number, &chunk.Info.Start, &chunk.Info.End, &chunk.ClusterID); err != nil {
-		return nil, fmt.Errorf("id %d not found: %w", id, err)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("chunk %d: %w", id, err)
 	}
 	chunk.ID = id
 	return &chunk, nil
 }
 
-func (obj *Database) Search(ctx context.Context, vec *pgvector.Vector, limit int) ([]*Chunk, error) {
-	if limit <= 0 {
-		return nil, nil
+func (obj *Database) DocumentChunks(ctx context.Context, docID int64, includeDeleted bool) ([]*Chunk, error) {
+	request := "SELECT " + chunkColumns + " FROM hackernews WHERE doc_id = $1"
+	if !includeDeleted {
+		request += " AND NOT deleted"
 	}
+	request += " ORDER BY chunk_no"
 
-	const request = `
-	SELECT
-		id, doc_id, title, author, text, time, type, score, deleted, dead, embedding, chunk_no, chunk_start, chunk_end, cluster_id
-	FROM hackernews
-	ORDER BY embedding <-> $1
-	LIMIT $2
-`
-	rows, err := obj.DB.QueryContext(ctx, request, vec, limit)
+	rows, err := obj.DB.QueryContext(ctx, request, docID)
 	if err != nil {
-		return nil, fmt.Errorf("search: %w", err)
+		return nil, fmt.Errorf("document chunks: %w", err)
 	}
 	defer rows.Close()
 
-	out := make([]*Chunk, 0, limit)
+	var out []*Chunk
 	for rows.Next() {
-		var chunk Chunk
-		if err := rows.Scan(
-			&chunk.ID, &chunk.DocID, &chunk.Title, &chunk.Author, &chunk.Text, &chunk.Time, &chunk.Type, &chunk.Score,
-			&chunk.Deleted, &chunk.Dead, &chunk.Embedding, &chunk.Info.Number, &chunk.Info.Start, &chunk.Info.End, &chunk.ClusterID,
-		); err != nil {
-			return nil, fmt.Errorf("scan: %w", err)
+		chunk, err := scanChunk(rows)
+		if err != nil {
+			return nil, err
 		}
-		out = append(out, &chunk)
+		out = append(out, chunk)
 	}
-	if err := rows.Err(); err != nil {
+	if err = rows.Err(); err != nil {
 		return nil, fmt.Errorf("rows: %w", err)
 	}
+	if len(out) == 0 {
+		return nil, ErrNotFound
+	}
 	return out, nil
 }
 
-func (obj *Database) SearchInClusters(
+// DocumentEmbedding returns the mean embedding of the document's live chunks.
+func (obj *Database) DocumentEmbedding(ctx context.Context, docID int64) (pgvector.Vector, error) {
+	const request = "SELECT AVG(embedding) FROM hackernews WHERE doc_id = $1 AND NOT deleted HAVING COUNT(*) > 0"
+
+	var vec pgvector.Vector
+	if err := obj.DB.QueryRowContext(ctx, request, docID).Scan(&vec); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return pgvector.Vector{}, ErrNotFound
+		}
+		return pgvector.Vector{}, fmt.Errorf("document embedding: %w", err)
+	}
+	return vec, nil
+}
+
+type ListOrder string
+
+const (
+	OrderByID   ListOrder = "id"
+	OrderByTime ListOrder = "time"
+)
+
+type ListCursor struct {
+	Time time.Time
+	ID   int64
+}
+
+// ListChunks pages through chunks with keyset pagination on id or (time, id).
+func (obj *Database) ListChunks(
 	ctx context.Context,
-	vec *pgvector.Vector,
-	clusterIDs []int32,
+	filter Filter,
+	order ListOrder,
+	after *ListCursor,
 	limit int,
 ) ([]*Chunk, error) {
-	if limit <= 0 || len(clusterIDs) == 0 {
+	if limit <= 0 {
 		return nil, nil
 	}
 
-	const req = `
-	SELECT
-	id, doc_id, title, author, text, time, type, score, deleted, dead, embedding, chunk_no, chunk_start, chunk_end,
-	cluster_id
-	FROM hackernews
-	WHERE cluster_id = ANY($2)
-	ORDER BY embedding <-> $1
-	LIMIT $3;
-`
+	var builder queryBuilder
+	builder.applyFilter(&filter)
+	orderBy := "id"
+	if order == OrderByTime {
+		orderBy = "time, id"
+		if after != nil {
+			builder.where("(time, id) > (" + builder.arg(after.Time) + ", " + builder.arg(after.ID) + ")")
+		}
+	} else if after != nil {
+		builder.where("id > " + builder.arg(after.ID))
+	}
+	limitArg := builder.arg(limit)
 
-	rows, err := obj.DB.QueryContext(ctx, req, vec, clusterIDs, limit)
+	request := "SELECT " + chunkColumns + " FROM hackernews " + builder.whereClause() +
+		" ORDER BY " + orderBy + " LIMIT " + limitArg
+	rows, err := obj.DB.QueryContext(ctx, request, builder.args...)
 	if err != nil {
-		return nil, fmt.Errorf("search in clusters: %w", err)
+		return nil, fmt.Errorf("list chunks: %w", err)
 	}
 	defer rows.Close()
 
 	out := make([]*Chunk, 0, limit)
 	for rows.Next() {
-		var chunk Chunk
-		if err = rows.Scan(
-			&chunk.ID, &chunk.DocID, &chunk.Title, &chunk.Author, &chunk.Text, &chunk.Time, &chunk.Type, &chunk.Score,
-			&chunk.Deleted, &chunk.Dead, &chunk.Embedding, &chunk.Info.Number, &chunk.Info.Start, &chunk.Info.End, &chunk.ClusterID,
-		); err != nil {
-			return nil, fmt.Errorf("scan: %w", err)
+		chunk, err := scanChunk(rows)
+		if err != nil {
+			return nil, err
 		}
-		out = append(out, &chunk)
+		out = append(out, chunk)
 	}
 	if err = rows.Err(); err != nil {
 		return nil, fmt.Errorf("rows: %w", err)
 	}
 	return out, nil
 }
+
+// SampleEmbeddings returns up to n random live rows; it scans the whole table
+// and is meant for offline tooling only.
+func (obj *Database) SampleEmbeddings(ctx context.Context, n int) ([]*ClusterPoint, error) {
+	const request = "SELECT id, embedding FROM hackernews WHERE NOT deleted ORDER BY random() LIMIT $1"
+	rows, err := obj.DB.QueryContext(ctx, request, n)
+	if err != nil {
+		return nil, fmt.Errorf("sample embeddings: %w", err)
+	}
+	defer rows.Close()
+
+	out := make([]*ClusterPoint, 0, n)
+	for rows.Next() {
+		var point ClusterPoint
+		if err = rows.Scan(&point.ID, &point.Embedding); err != nil {
+			return nil, fmt.Errorf("sample embeddings scan: %w", err)
+		}
+		out = append(out, &point)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("sample embeddings rows: %w", err)
+	}
+	return out, nil
+}
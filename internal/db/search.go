@@ -0,0 +1,215 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+
+	"github.com/pgvector/pgvector-go"
+)
+
+const chunkColumns = "id, doc_id, title, author, text, time, type, score, deleted, dead, embedding, " +
+	"chunk_no, chunk_start, chunk_end, cluster_id"
+
+type SearchOptions struct {
+	Filter      Filter
+	Metric      Metric
+	MaxDistance *float64
+	After       *SearchCursor
+	// EfSearch overrides hnsw.ef_search for this query only; 0 keeps the
+	// server setting.
+	EfSearch int
+	// Probes overrides ivfflat.probes the same way.
+	Probes int
+	// NProbe restricts the search to the rows of the NProbe clusters whose
+	// stored centroids are nearest to the query vector. It is a filter, not a
+	// coarse index: under an HNSW scan it drops candidates outside those
+	// clusters, so a page can come back short, and rows without a cluster_id
+	// from the current run (not yet backfilled) are never returned. With no
+	// stored centroids the filter is skipped.
+	NProbe int
+	// Exact disables index scans so the query returns true nearest
+	// neighbours by a sequential scan.
+	Exact bool
+	// Transaction runs the query in the settings transaction even when no
+	// setting applies, so its latency compares with that of an Exact query.
+	Transaction bool
+}
+
+// SearchCursor resumes a search after the last returned hit. Rows are ordered
+// by distance, then id, so rows at equal distance (duplicate embeddings) are
+// neither skipped nor repeated across pages. The index still serves the
+// distance order and Postgres sorts ties incrementally.
+//
+// An HNSW scan applies the cursor predicate to the ef_search candidates it
+// has already gathered, so a later page comes back short once the earlier
+// pages use up the candidate set; callers raise EfSearch for cursor pages.
+type SearchCursor struct {
+	Distance float64
+	ID       int64
+}
+
+type SearchHit struct {
+	Chunk
+	Distance float64
+	TextRank float64
+}
+
+func (obj *Database) Search(
+	ctx context.Context,
+	vec *pgvector.Vector,
+	limit int,
+	opts SearchOptions,
+) ([]*SearchHit, error) {
+	if limit <= 0 {
+		return nil, nil
+	}
+	return obj.search(ctx, vec, limit, opts)
+}
+
+func (obj *Database) SearchInClusters(
+	ctx context.Context,
+	vec *pgvector.Vector,
+	clusterIDs []int32,
+	limit int,
+	opts SearchOptions,
+) ([]*SearchHit, error) {
+	if limit <= 0 || len(clusterIDs) == 0 {
+		return nil, nil
+	}
+	opts.Filter.ClusterIDs = clusterIDs
+	return obj.search(ctx, vec, limit, opts)
+}
+
+func (obj *Database) search(
+	ctx context.Context,
+	vec *pgvector.Vector,
+	limit int,
+	opts SearchOptions,
+) ([]*SearchHit, error) {
+	var builder queryBuilder
+	vecArg := builder.arg(vec)
+	distance := "embedding " + opts.Metric.Operator() + " " + vecArg
+	builder.applyFilter(&opts.Filter)
+	if opts.NProbe > 0 {
+		// Centroids are ranked by L2 whatever the query metric is; for the
+		// unit-length centroids of a cosine run this matches cosine order.
+		// Cluster ids from an older run number different clusters, so only
+		// rows assigned by the current run pass.
+		builder.where("(NOT EXISTS (SELECT 1 FROM clusters) OR (cluster_id IN (SELECT id FROM clusters " +
+			"ORDER BY centroid <-> " + vecArg + " LIMIT " + builder.arg(opts.NProbe) + ") " +
+			"AND cluster_run_id = (SELECT run_id FROM clusters LIMIT 1)))")
+	}
+	if opts.MaxDistance != nil {
+		builder.where(distance + " <= " + builder.arg(*opts.MaxDistance))
+	}
+	if opts.After != nil {
+		after, afterID := builder.arg(opts.After.Distance), builder.arg(opts.After.ID)
+		builder.where("(" + distance + " > " + after + " OR (" + distance + " = " + after + " AND id > " + afterID + "))")
+	}
+	limitArg := builder.arg(limit)
+
+	request := "SELECT " + chunkColumns + ", " + distance + " AS distance FROM hackernews " +
+		builder.whereClause() + " ORDER BY " + distance + ", id LIMIT " + limitArg
+
+	out := make([]*SearchHit, 0, limit)
+	err := obj.withSettings(ctx, opts.settings(), opts.Transaction, func(conn querier) error {
+		rows, err := conn.QueryContext(ctx, request, builder.args...)
+		if err != nil {
+			return fmt.Errorf("search: %w", err)
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var distance float64
+			chunk, err := scanChunk(rows, &distance)
+			if err != nil {
+				return err
+			}
+			out = append(out, &SearchHit{Chunk: *chunk, Distance: distance})
+		}
+		if err = rows.Err(); err != nil {
+			return fmt.Errorf("rows: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (obj SearchOptions) settings() []string {
+	var out []string
+	if obj.EfSearch > 0 {
+		out = append(out, "hnsw.ef_search = "+strconv.Itoa(obj.EfSearch))
+	}
+	if obj.Probes > 0 {
+		out = append(out, "ivfflat.probes = "+strconv.Itoa(obj.Probes))
+	}
+	if obj.Exact {
+		out = append(out, "enable_indexscan = off")
+	}
+	return out
+}
+
+func (obj *Database) LexicalSearch(
+	ctx context.Context,
+	vec *pgvector.Vector,
+	query string,
+	limit int,
+	opts SearchOptions,
+) ([]*SearchHit, error) {
+	if limit <= 0 || query == "" {
+		return nil, nil
+	}
+
+	var builder queryBuilder
+	distance := "embedding " + opts.Metric.Operator() + " " + builder.arg(vec)
+	tsQuery := "websearch_to_tsquery('english', " + builder.arg(query) + ")"
+	builder.where("search_tsv @@ " + tsQuery)
+	builder.applyFilter(&opts.Filter)
+	if opts.MaxDistance != nil {
+		builder.where(distance + " <= " + builder.arg(*opts.MaxDistance))
+	}
+	limitArg := builder.arg(limit)
+
+	rank := "ts_rank(search_tsv, " + tsQuery + ")"
+	request := "SELECT " + chunkColumns + ", " + distance + " AS distance, " + rank + " AS text_rank " +
+		"FROM hackernews " + builder.whereClause() + " ORDER BY text_rank DESC, id LIMIT " + limitArg
+
+	rows, err := obj.DB.QueryContext(ctx, request, builder.args...)
+	if err != nil {
+		return nil, fmt.Errorf("lexical search: %w", err)
+	}
+	defer rows.Close()
+
+	out := make([]*SearchHit, 0, limit)
+	for rows.Next() {
+		var hit SearchHit
+		chunk, err := scanChunk(rows, &hit.Distance, &hit.TextRank)
+		if err != nil {
+			return nil, err
+		}
+		hit.Chunk = *chunk
+		out = append(out, &hit)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows: %w", err)
+	}
+	return out, nil
+}
+
+func scanChunk(rows *sql.Rows, extra ...any) (*Chunk, error) {
+	var chunk Chunk
+	dest := []any{
+		&chunk.ID, &chunk.DocID, &chunk.Title, &chunk.Author, &chunk.Text, &chunk.Time, &chunk.Type, &chunk.Score,
+		&chunk.Deleted, &chunk.Dead, &chunk.Embedding, &chunk.Info.Number, &chunk.Info.Start, &chunk.Info.End,
+		&chunk.ClusterID,
+	}
+	if err := rows.Scan(append(dest, extra...)...); err != nil {
+		return nil, fmt.Errorf("scan: %w", err)
+	}
+	return &chunk, nil
+}
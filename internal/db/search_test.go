@@ -0,0 +1,107 @@
+package db
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/pgvector/pgvector-go"
+)
+
+// testDatabase connects to EMBED_STORE_TEST_DSN, which must name a migrated
+// database the test may write to: it replaces the current clusters.
+func testDatabase(t *testing.T) *Database {
+	t.Helper()
+	dsn := os.Getenv("EMBED_STORE_TEST_DSN")
+	if dsn == "" {
+		t.Skip("EMBED_STORE_TEST_DSN is not set")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	database, err := Connect(dsn, ctx)
+	if err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	t.Cleanup(func() { _ = database.DB.Close() })
+	return &database
+}
+
+// axis returns a unit vector along dimension dim, tilted by tilt along the
+// next one.
+func axis(dim int, tilt float32) []float32 {
+	vec := make([]float32, VectorSize)
+	vec[dim] = 1
+	vec[dim+1] = tilt
+	return vec
+}
+
+func TestSearchNProbeSkipsOlderRuns(t *testing.T) {
+	database := testDatabase(t)
+	ctx := context.Background()
+
+	runID, err := database.NextClusterRunID(ctx)
+	if err != nil {
+		t.Fatalf("NextClusterRunID: %v", err)
+	}
+	// Cluster 0 sits on axis 0 and cluster 1 on axis 2.
+	run := &ClusterRun{
+		ID:        runID,
+		Metric:    MetricL2,
+		Points:    2,
+		Centroids: [][]float32{axis(0, 0), axis(2, 0)},
+		Sizes:     []int{1, 1},
+		Inertia:   []float64{0, 0},
+	}
+	if _, err = database.SaveClusterRun(ctx, run); err != nil {
+		t.Fatalf("SaveClusterRun: %v", err)
+	}
+
+	docID := -time.Now().UnixNano()
+	t.Cleanup(func() {
+		_, _ = database.DB.ExecContext(context.Background(), "DELETE FROM hackernews WHERE doc_id = $1", docID)
+	})
+	olderRunID := runID - 1
+	rows := []struct {
+		embedding []float32
+		clusterID int32
+		runID     *int64
+	}{
+		{embedding: axis(0, 0.2), clusterID: 0, runID: &runID},      // current run, nearest cluster
+		{embedding: axis(0, 0.1), clusterID: 0, runID: &olderRunID}, // same id from an older run
+		{embedding: axis(0, 0.3), clusterID: 0, runID: nil},         // not yet backfilled
+		{embedding: axis(2, 0.1), clusterID: 1, runID: &runID},      // current run, other cluster
+	}
+	ids := make([]int64, len(rows))
+	for idx, row := range rows {
+		clusterID := row.clusterID
+		chunk := &Chunk{
+			DocID:        docID,
+			Text:         "nprobe",
+			Time:         time.Now(),
+			Type:         "comment",
+			Embedding:    pgvector.NewVector(row.embedding),
+			ClusterID:    &clusterID,
+			ClusterRunID: row.runID,
+			Info:         Metadata{Number: int32(idx)},
+		}
+		if ids[idx], err = database.InsertChunk(ctx, chunk); err != nil {
+			t.Fatalf("InsertChunk %d: %v", idx, err)
+		}
+	}
+
+	query := pgvector.NewVector(axis(0, 0))
+	opts := SearchOptions{Filter: Filter{DocIDs: []int64{docID}}, NProbe: 1, Exact: true}
+	hits, err := database.Search(ctx, &query, len(rows), opts)
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if len(hits) != 1 || hits[0].ID != ids[0] {
+		got := make([]int64, len(hits))
+		for idx, hit := range hits {
+			got[idx] = hit.ID
+		}
+		t.Fatalf("Search returned ids %v, want [%d]", got, ids[0])
+	}
+}
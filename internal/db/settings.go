@@ -0,0 +1,44 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// withSettings runs fn on the pool directly, or, when settings are given or
+// inTx is set, inside a read-only transaction that applies them with SET
+// LOCAL so they never leak to other queries sharing the connection.
+func (obj *Database) withSettings(
+	ctx context.Context,
+	settings []string,
+	inTx bool,
+	fn func(conn querier) error,
+) error {
+	if len(settings) == 0 && !inTx {
+		return fn(obj.DB)
+	}
+
+	tx, err := obj.DB.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return fmt.Errorf("begin: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	for _, setting := range settings {
+		if _, err = tx.ExecContext(ctx, "SET LOCAL "+setting); err != nil {
+			return fmt.Errorf("set local %s: %w", setting, err)
+		}
+	}
+	if err = fn(tx); err != nil {
+		return err
+	}
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("commit: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,281 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/pgvector/pgvector-go"
+)
+
+const upsertSet = `
+	ON CONFLICT (doc_id, chunk_no) DO UPDATE SET
+		title = EXCLUDED.title,
+		author = EXCLUDED.author,
+		text = EXCLUDED.text,
+		time = EXCLUDED.time,
+		type = EXCLUDED.type,
+		score = EXCLUDED.score,
+		deleted = EXCLUDED.deleted,
+		dead = EXCLUDED.dead,
+		embedding = EXCLUDED.embedding,
+		chunk_start = EXCLUDED.chunk_start,
+		chunk_end = EXCLUDED.chunk_end,
+		cluster_id = EXCLUDED.cluster_id,
+		cluster_run_id = EXCLUDED.cluster_run_id
+`
+
+type ChunkPatch struct {
+	Title     *string
+	Author    *string
+	Text      *string
+	Score     *int32
+	Deleted   *bool
+	Dead      *bool
+	Embedding *pgvector.Vector
+	// ClusterID and ClusterRunID are written along with Embedding (nil clears
+	// them) and ignored otherwise.
+	ClusterID    *int32
+	ClusterRunID *int64
+}
+
+// UpsertChunk inserts the chunk or overwrites the row with the same
+// (doc_id, chunk_no) and reports whether a new row was created.
+func (obj *Database) UpsertChunk(ctx context.Context, chunk *Chunk) (bool, error) {
+	const request = "INSERT INTO hackernews " +
+		"(doc_id, title, author, text, time, type, score, deleted, dead, embedding, chunk_no, chunk_start, chunk_end, " +
+		"cluster_id, cluster_run_id) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)" +
+		upsertSet +
+		"RETURNING id, (xmax = 0)"
+	if chunk == nil {
+		return false, ErrChunkNil
+	}
+
+	var created bool
+	row := obj.DB.QueryRowContext(ctx, request,
+		chunk.DocID, chunk.Title, chunk.Author, chunk.Text, chunk.Time, chunk.Type, chunk.Score, chunk.Deleted, chunk.Dead,
+		chunk.Embedding, chunk.Info.Number, chunk.Info.Start, chunk.Info.End, chunk.ClusterID, chunk.ClusterRunID)
+	if err := row.Scan(&chunk.ID, &created); err != nil {
+		return false, fmt.Errorf("upsert failed: %w", err)
+	}
+	return created, nil
+}
+
+// UpsertBatch applies the batch as if the rows were upserted one by one:
+// repeated (doc_id, chunk_no) keys keep the last values, and created[i] is true
+// only for the first occurrence of a key that did not exist before.
+func (obj *Database) UpsertBatch(ctx context.Context, batch []*Chunk) ([]bool, error) {
+	created := make([]bool, len(batch))
+	if len(batch) == 0 {
+		return created, nil
+	}
+
+	last := make(map[chunkKey]int, len(batch))
+	for idx, chunk := range batch {
+		if chunk == nil {
+			return nil, fmt.Errorf("nil chunk in batch. index: %d", idx)
+		}
+		last[chunkKey{docID: chunk.DocID, number: chunk.Info.Number}] = idx
+	}
+
+	const columnsPerRow = 15
+	var queryBuilder strings.Builder
+	queryBuilder.Grow(512 + len(last)*columnsPerRow*6)
+	queryBuilder.WriteString(`
+	INSERT INTO hackernews (
+		doc_id, title, author, text, time, type, score, deleted, dead, embedding,
+		chunk_no, chunk_start, chunk_end, cluster_id, cluster_run_id
+	 ) VALUES
+`)
+
+	args := make([]any, 0, len(last)*columnsPerRow)
+	argIdx := 1
+	for idx, chunk := range batch {
+		if last[chunkKey{docID: chunk.DocID, number: chunk.Info.Number}] != idx {
+			continue
+		}
+		if len(args) > 0 {
+			queryBuilder.WriteByte(',')
+		}
+		queryBuilder.WriteByte('(')
+		for col := range columnsPerRow {
+			if col > 0 {
+				queryBuilder.WriteByte(',')
+			}
+			queryBuilder.WriteString(fmt.Sprintf("$%d", argIdx))
+			argIdx++
+		}
+		queryBuilder.WriteByte(')')
+
+		args = append(args,
+			chunk.DocID, chunk.Title, chunk.Author, chunk.Text, chunk.Time, chunk.Type, chunk.Score,
+			chunk.Deleted, chunk.Dead, chunk.Embedding, chunk.Info.Number, chunk.Info.Start, chunk.Info.End,
+			chunk.ClusterID, chunk.ClusterRunID,
+		)
+	}
+	queryBuilder.WriteString(upsertSet)
+	queryBuilder.WriteString(" RETURNING id, doc_id, chunk_no, (xmax = 0)")
+
+	rows, err := obj.DB.QueryContext(ctx, queryBuilder.String(), args...)
+	if err != nil {
+		return nil, fmt.Errorf("batch upsert failed: %w", err)
+	}
+	defer rows.Close()
+
+	type upserted struct {
+		id      int64
+		created bool
+	}
+	results := make(map[chunkKey]upserted, len(last))
+	for rows.Next() {
+		var (
+			key   chunkKey
+			value upserted
+		)
+		if err = rows.Scan(&value.id, &key.docID, &key.number, &value.created); err != nil {
+			return nil, fmt.Errorf("batch upsert scan: %w", err)
+		}
+		results[key] = value
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("batch upsert rows: %w", err)
+	}
+
+	seen := make(map[chunkKey]struct{}, len(last))
+	for idx, chunk := range batch {
+		key := chunkKey{docID: chunk.DocID, number: chunk.Info.Number}
+		value := results[key]
+		chunk.ID = value.id
+		if _, ok := seen[key]; !ok {
+			created[idx] = value.created
+			seen[key] = struct{}{}
+		}
+	}
+	return created, nil
+}
+
+// UpdateChunk replaces every column of the row, cluster assignment included:
+// a nil ClusterID leaves the row to the cluster backfill.
+func (obj *Database) UpdateChunk(ctx context.Context, id int64, chunk *Chunk) error {
+	const request = "UPDATE hackernews SET " +
+		"doc_id = $2, title = $3, author = $4, text = $5, time = $6, type = $7, score = $8, deleted = $9, " +
+		"dead = $10, embedding = $11, chunk_no = $12, chunk_start = $13, chunk_end = $14, cluster_id = $15, " +
+		"cluster_run_id = $16 WHERE id = $1 RETURNING id"
+	if chunk == nil {
+		return ErrChunkNil
+	}
+
+	row := obj.DB.QueryRowContext(ctx, request, id,
+		chunk.DocID, chunk.Title, chunk.Author, chunk.Text, chunk.Time, chunk.Type, chunk.Score, chunk.Deleted, chunk.Dead,
+		chunk.Embedding, chunk.Info.Number, chunk.Info.Start, chunk.Info.End, chunk.ClusterID, chunk.ClusterRunID)
+	if err := row.Scan(&chunk.ID); err != nil {
+		var pgErr *pgconn.PgError
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrNotFound
+		case errors.As(err, &pgErr) && pgErr.Code == uniqueErrCode:
+			return ErrDuplicateKey
+		}
+		return fmt.Errorf("update failed: %w", err)
+	}
+	return nil
+}
+
+func (obj *Database) PatchChunk(ctx context.Context, id int64, patch *ChunkPatch) (*Chunk, error) {
+	if patch == nil {
+		return nil, ErrChunkNil
+	}
+
+	var builder queryBuilder
+	idArg := builder.arg(id)
+	sets := make([]string, 0, 9)
+	set := func(column string, value any) {
+		sets = append(sets, column+" = "+builder.arg(value))
+	}
+	if patch.Title != nil {
+		set("title", *patch.Title)
+	}
+	if patch.Author != nil {
+		set("author", *patch.Author)
+	}
+	if patch.Text != nil {
+		set("text", *patch.Text)
+	}
+	if patch.Score != nil {
+		set("score", *patch.Score)
+	}
+	if patch.Deleted != nil {
+		set("deleted", *patch.Deleted)
+	}
+	if patch.Dead != nil {
+		set("dead", *patch.Dead)
+	}
+	if patch.Embedding != nil {
+		set("embedding", patch.Embedding)
+		set("cluster_id", patch.ClusterID)
+		set("cluster_run_id", patch.ClusterRunID)
+	}
+	if len(sets) == 0 {
+		return obj.ChunkByID(ctx, id)
+	}
+
+	request := "UPDATE hackernews SET " + strings.Join(sets, ", ") + " WHERE id = " + idArg +
+		" RETURNING " + chunkColumns
+	rows, err := obj.DB.QueryContext(ctx, request, builder.args...)
+	if err != nil {
+		return nil, fmt.Errorf("patch failed: %w", err)
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err = rows.Err(); err != nil {
+			return nil, fmt.Errorf("patch failed: %w", err)
+		}
+		return nil, ErrNotFound
+	}
+	return scanChunk(rows)
+}
+
+// DeleteChunk removes the row, or only marks it deleted when soft is set.
+func (obj *Database) DeleteChunk(ctx context.Context, id int64, soft bool) error {
+	request := "DELETE FROM hackernews WHERE id = $1"
+	if soft {
+		request = "UPDATE hackernews SET deleted = true WHERE id = $1"
+	}
+
+	result, err := obj.DB.ExecContext(ctx, request, id)
+	if err != nil {
+		return fmt.Errorf("delete chunk: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("rows affected: %w", err)
+	}
+	if affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (obj *Database) DeleteDocument(ctx context.Context, docID int64, soft bool) (int64, error) {
+	request := "DELETE FROM hackernews WHERE doc_id = $1"
+	if soft {
+		request = "UPDATE hackernews SET deleted = true WHERE doc_id = $1"
+	}
+
+	result, err := obj.DB.ExecContext(ctx, request, docID)
+	if err != nil {
+		return 0, fmt.Errorf("delete document: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("rows affected: %w", err)
+	}
+	if affected == 0 {
+		return 0, ErrNotFound
+	}
+	return affected, nil
+}
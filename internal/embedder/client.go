@@ -0,0 +1,154 @@
+package embedder
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/atroxxxxxx/embed-store/internal/db"
+	"github.com/pgvector/pgvector-go"
+)
+
+const (
+	FormatOpenAI = "openai"
+	FormatTEI    = "tei"
+)
+
+type Config struct {
+	URL     string
+	Format  string
+	Model   string
+	APIKey  string
+	Timeout int
+}
+
+var (
+	ErrEmptyURL         = errors.New("embedder url is empty")
+	ErrUnknownFormat    = errors.New("unknown embedder format")
+	ErrEmptyText        = errors.New("text is empty")
+	ErrEmptyResponse    = errors.New("embedder returned no vectors")
+	ErrInvalidDimension = errors.New("embedder returned invalid vector dimension")
+)
+
+// Client talks to an OpenAI-compatible /v1/embeddings endpoint or to a
+// Text Embeddings Inference /embed endpoint, depending on Config.Format.
+type Client struct {
+	cfg    Config
+	client *http.Client
+}
+
+type openAIRequest struct {
+	Model string   `json:"model,omitempty"`
+	Input []string `json:"input"`
+}
+
+type openAIResponse struct {
+	Data []struct {
+		Embedding []float32 `json:"embedding"`
+		Index     int       `json:"index"`
+	} `json:"data"`
+}
+
+type teiRequest struct {
+	Inputs []string `json:"inputs"`
+}
+
+func New(cfg Config) (*Client, error) {
+	if cfg.URL == "" {
+		return nil, ErrEmptyURL
+	}
+	cfg.Format = strings.TrimSpace(strings.ToLower(cfg.Format))
+	if cfg.Format == "" {
+		cfg.Format = FormatOpenAI
+	}
+	if cfg.Format != FormatOpenAI && cfg.Format != FormatTEI {
+		return nil, ErrUnknownFormat
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10
+	}
+
+	return &Client{
+		cfg:    cfg,
+		client: &http.Client{Timeout: time.Duration(cfg.Timeout) * time.Second},
+	}, nil
+}
+
+func (obj *Client) Embed(ctx context.Context, text string) (pgvector.Vector, error) {
+	if strings.TrimSpace(text) == "" {
+		return pgvector.Vector{}, ErrEmptyText
+	}
+
+	var payload any
+	if obj.cfg.Format == FormatTEI {
+		payload = teiRequest{Inputs: []string{text}}
+	} else {
+		payload = openAIRequest{Model: obj.cfg.Model, Input: []string{text}}
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return pgvector.Vector{}, fmt.Errorf("encode embed request: %w", err)
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, obj.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return pgvector.Vector{}, fmt.Errorf("build embed request: %w", err)
+	}
+	request.Header.Set("Content-Type", "application/json")
+	if obj.cfg.APIKey != "" {
+		request.Header.Set("Authorization", "Bearer "+obj.cfg.APIKey)
+	}
+
+	response, err := obj.client.Do(request)
+	if err != nil {
+		return pgvector.Vector{}, fmt.Errorf("embed request: %w", err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(response.Body, 512))
+		return pgvector.Vector{}, fmt.Errorf("embed request: status %d: %s", response.StatusCode, message)
+	}
+
+	var vector []float32
+	if obj.cfg.Format == FormatTEI {
+		vector, err = decodeTEI(response.Body)
+	} else {
+		vector, err = decodeOpenAI(response.Body)
+	}
+	if err != nil {
+		return pgvector.Vector{}, err
+	}
+	if len(vector) != db.VectorSize {
+		return pgvector.Vector{}, fmt.Errorf("%w: %d != %d", ErrInvalidDimension, len(vector), db.VectorSize)
+	}
+	return pgvector.NewVector(vector), nil
+}
+
+func decodeOpenAI(body io.Reader) ([]float32, error) {
+	var resp openAIResponse
+	if err := json.NewDecoder(body).Decode(&resp); err != nil {
+		return nil, fmt.Errorf("decode embed response: %w", err)
+	}
+	if len(resp.Data) == 0 {
+		return nil, ErrEmptyResponse
+	}
+	return resp.Data[0].Embedding, nil
+}
+
+func decodeTEI(body io.Reader) ([]float32, error) {
+	var resp [][]float32
+	if err := json.NewDecoder(body).Decode(&resp); err != nil {
+		return nil, fmt.Errorf("decode embed response: %w", err)
+	}
+	if len(resp) == 0 {
+		return nil, ErrEmptyResponse
+	}
+	return resp[0], nil
+}
@@ -0,0 +1,160 @@
+package embedder
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/atroxxxxxx/embed-store/internal/db"
+)
+
+func vector(size int) []float32 {
+	vec := make([]float32, size)
+	for i := range vec {
+		vec[i] = float32(i) / float32(size)
+	}
+	return vec
+}
+
+// stubServer answers like an embedding server in the given format, returning
+// vectors of size dim.
+func stubServer(t *testing.T, format string, dim int) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if request.Method != http.MethodPost {
+			t.Errorf("method = %s, want POST", request.Method)
+		}
+		if got := request.Header.Get("Authorization"); got != "Bearer key" {
+			t.Errorf("Authorization = %q, want %q", got, "Bearer key")
+		}
+
+		var input []string
+		if format == FormatTEI {
+			var req teiRequest
+			if err := json.NewDecoder(request.Body).Decode(&req); err != nil {
+				t.Errorf("decode tei request: %v", err)
+			}
+			input = req.Inputs
+		} else {
+			var req openAIRequest
+			if err := json.NewDecoder(request.Body).Decode(&req); err != nil {
+				t.Errorf("decode openai request: %v", err)
+			}
+			if req.Model != "model" {
+				t.Errorf("model = %q, want %q", req.Model, "model")
+			}
+			input = req.Input
+		}
+		if len(input) != 1 || input[0] != "hello" {
+			t.Errorf("input = %v, want [hello]", input)
+		}
+
+		writer.Header().Set("Content-Type", "application/json")
+		if format == FormatTEI {
+			_ = json.NewEncoder(writer).Encode([][]float32{vector(dim)})
+			return
+		}
+		_ = json.NewEncoder(writer).Encode(map[string]any{
+			"data": []map[string]any{{"embedding": vector(dim), "index": 0}},
+		})
+	}))
+}
+
+func newClient(t *testing.T, url, format string) *Client {
+	t.Helper()
+	client, err := New(Config{URL: url, Format: format, Model: "model", APIKey: "key"})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return client
+}
+
+func TestEmbed(t *testing.T) {
+	for _, format := range []string{FormatOpenAI, FormatTEI} {
+		t.Run(format, func(t *testing.T) {
+			server := stubServer(t, format, db.VectorSize)
+			defer server.Close()
+
+			vec, err := newClient(t, server.URL, format).Embed(context.Background(), "hello")
+			if err != nil {
+				t.Fatalf("Embed: %v", err)
+			}
+			got := vec.Slice()
+			if len(got) != db.VectorSize || got[1] != vector(db.VectorSize)[1] {
+				t.Fatalf("Embed returned %d dims, want %d", len(got), db.VectorSize)
+			}
+		})
+	}
+}
+
+func TestEmbedInvalidDimension(t *testing.T) {
+	for _, format := range []string{FormatOpenAI, FormatTEI} {
+		t.Run(format, func(t *testing.T) {
+			server := stubServer(t, format, db.VectorSize-1)
+			defer server.Close()
+
+			_, err := newClient(t, server.URL, format).Embed(context.Background(), "hello")
+			if !errors.Is(err, ErrInvalidDimension) {
+				t.Fatalf("Embed error = %v, want %v", err, ErrInvalidDimension)
+			}
+		})
+	}
+}
+
+func TestEmbedStatus(t *testing.T) {
+	for _, format := range []string{FormatOpenAI, FormatTEI} {
+		t.Run(format, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, _ *http.Request) {
+				http.Error(writer, "model overloaded", http.StatusServiceUnavailable)
+			}))
+			defer server.Close()
+
+			_, err := newClient(t, server.URL, format).Embed(context.Background(), "hello")
+			if err == nil || !strings.Contains(err.Error(), "status 503") ||
+				!strings.Contains(err.Error(), "model overloaded") {
+				t.Fatalf("Embed error = %v, want status 503 with body", err)
+			}
+		})
+	}
+}
+
+func TestEmbedTimeout(t *testing.T) {
+	for _, format := range []string{FormatOpenAI, FormatTEI} {
+		t.Run(format, func(t *testing.T) {
+			release := make(chan struct{})
+			server := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, request *http.Request) {
+				select {
+				case <-request.Context().Done():
+				case <-release:
+				}
+			}))
+			defer server.Close()
+			defer close(release)
+
+			client := newClient(t, server.URL, format)
+			// Config.Timeout is in whole seconds; shorten it to keep the test fast.
+			client.client.Timeout = 50 * time.Millisecond
+
+			start := time.Now()
+			_, err := client.Embed(context.Background(), "hello")
+			if err == nil {
+				t.Fatal("Embed succeeded, want timeout")
+			}
+			if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+				t.Fatalf("Embed took %s, want it to time out", elapsed)
+			}
+		})
+	}
+}
+
+func TestEmbedEmptyText(t *testing.T) {
+	client := newClient(t, "http://127.0.0.1:0", FormatOpenAI)
+	if _, err := client.Embed(context.Background(), "  "); !errors.Is(err, ErrEmptyText) {
+		t.Fatalf("Embed error = %v, want %v", err, ErrEmptyText)
+	}
+}
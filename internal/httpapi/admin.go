@@ -0,0 +1,231 @@
+package httpapi
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+
+	database "github.com/atroxxxxxx/embed-store/internal/db"
+	"go.uber.org/zap"
+)
+
+type IndexRequest struct {
+	Name           string `json:"name"`
+	Method         string `json:"method"`
+	Metric         string `json:"metric,omitempty"`
+	Lists          int    `json:"lists,omitempty"`
+	M              int    `json:"m,omitempty"`
+	EfConstruction int    `json:"ef_construction,omitempty"`
+}
+
+type IndexResponse struct {
+	Name      string   `json:"name"`
+	Method    string   `json:"method"`
+	OpClass   string   `json:"opclass"`
+	Options   []string `json:"options"`
+	SizeBytes int64    `json:"size_bytes"`
+	Valid     bool     `json:"valid"`
+	// BuildError is why the last background build of this index failed.
+	BuildError string `json:"build_error,omitempty"`
+}
+
+type IndexProgressResponse struct {
+	PID         int64  `json:"pid"`
+	Index       string `json:"index"`
+	Phase       string `json:"phase"`
+	BlocksDone  int64  `json:"blocks_done"`
+	BlocksTotal int64  `json:"blocks_total"`
+	TuplesDone  int64  `json:"tuples_done"`
+	TuplesTotal int64  `json:"tuples_total"`
+}
+
+// indexBuilds keeps the error of the last failed background build per index
+// name, so a caller that got 202 can find out how the build ended.
+type indexBuilds struct {
+	mutex  sync.Mutex
+	failed map[string]*IndexResponse
+}
+
+func newIndexBuilds() *indexBuilds {
+	return &indexBuilds{failed: make(map[string]*IndexResponse)}
+}
+
+func (obj *indexBuilds) fail(spec database.IndexSpec, err error) {
+	obj.mutex.Lock()
+	defer obj.mutex.Unlock()
+	obj.failed[spec.Name] = &IndexResponse{Name: spec.Name, Method: spec.Method, BuildError: err.Error()}
+}
+
+func (obj *indexBuilds) forget(name string) {
+	obj.mutex.Lock()
+	defer obj.mutex.Unlock()
+	delete(obj.failed, name)
+}
+
+// annotate sets BuildError on the listed indexes and appends failed builds
+// that left no index behind.
+func (obj *indexBuilds) annotate(resp []*IndexResponse) []*IndexResponse {
+	obj.mutex.Lock()
+	defer obj.mutex.Unlock()
+	listed := make(map[string]struct{}, len(resp))
+	for _, index := range resp {
+		listed[index.Name] = struct{}{}
+		if failed, ok := obj.failed[index.Name]; ok {
+			index.BuildError = failed.BuildError
+		}
+	}
+	for name, failed := range obj.failed {
+		if _, ok := listed[name]; !ok {
+			item := *failed
+			resp = append(resp, &item)
+		}
+	}
+	return resp
+}
+
+func (obj *Handler) requireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	expected := []byte("Bearer " + obj.cfg.AdminToken)
+	return func(writer http.ResponseWriter, request *http.Request) {
+		got := []byte(request.Header.Get("Authorization"))
+		if subtle.ConstantTimeCompare(got, expected) != 1 {
+			obj.sendErrResponse(writer, "unauthorized", http.StatusUnauthorized, nil)
+			return
+		}
+		next(writer, request)
+	}
+}
+
+func (obj *Handler) indexes(writer http.ResponseWriter, request *http.Request) {
+	switch request.Method {
+	case http.MethodGet:
+		obj.listIndexes(writer, request)
+	case http.MethodPost:
+		obj.createIndex(writer, request)
+	default:
+		obj.sendErrResponse(writer, "method not allowed", http.StatusMethodNotAllowed, nil)
+	}
+}
+
+func (obj *Handler) index(writer http.ResponseWriter, request *http.Request) {
+	switch {
+	case request.Method == http.MethodGet && request.URL.Path == "/admin/indexes/progress":
+		obj.indexProgress(writer, request)
+	case request.Method == http.MethodDelete:
+		obj.dropIndex(writer, request)
+	default:
+		obj.sendErrResponse(writer, "method not allowed", http.StatusMethodNotAllowed, nil)
+	}
+}
+
+func (obj *Handler) listIndexes(writer http.ResponseWriter, request *http.Request) {
+	indexes, err := obj.db.ListIndexes(request.Context())
+	if err != nil {
+		obj.sendErrResponse(writer, "internal server error", http.StatusInternalServerError, err)
+		return
+	}
+
+	resp := make([]*IndexResponse, 0, len(indexes))
+	for _, index := range indexes {
+		resp = append(resp, &IndexResponse{
+			Name:      index.Name,
+			Method:    index.Method,
+			OpClass:   index.OpClass,
+			Options:   index.Options,
+			SizeBytes: index.SizeBytes,
+			Valid:     index.Valid,
+		})
+	}
+	obj.sendJSON(writer, http.StatusOK, obj.builds.annotate(resp))
+}
+
+// createIndex starts a concurrent build in the background and answers 202;
+// the build outlives the request and a failure shows up as build_error in
+// the index listing until the index is built or dropped.
+func (obj *Handler) createIndex(writer http.ResponseWriter, request *http.Request) {
+	var req IndexRequest
+	dec := json.NewDecoder(request.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		obj.sendErrResponse(writer, "bad request", http.StatusBadRequest, err)
+		return
+	}
+
+	metric, err := database.ParseMetric(req.Metric)
+	if err != nil {
+		obj.sendErrResponse(writer, "bad request: "+err.Error(), http.StatusBadRequest, err)
+		return
+	}
+	spec := database.IndexSpec{
+		Name:           req.Name,
+		Method:         req.Method,
+		Metric:         metric,
+		Lists:          req.Lists,
+		M:              req.M,
+		EfConstruction: req.EfConstruction,
+	}
+	if err = spec.Validate(); err != nil {
+		obj.sendErrResponse(writer, "bad request: "+err.Error(), http.StatusBadRequest, err)
+		return
+	}
+
+	ctx := context.WithoutCancel(request.Context())
+	obj.builds.forget(spec.Name)
+	go func() {
+		obj.logger.Info("index build started", zap.String("name", spec.Name), zap.String("method", spec.Method))
+		if err := obj.db.CreateIndex(ctx, spec); err != nil {
+			obj.builds.fail(spec, err)
+			obj.logger.Error("index build failed", zap.String("name", spec.Name), zap.Error(err))
+			return
+		}
+		obj.logger.Info("index build finished", zap.String("name", spec.Name))
+	}()
+
+	req.Metric, req.Lists, req.M, req.EfConstruction = string(spec.Metric), spec.Lists, spec.M, spec.EfConstruction
+	obj.sendJSON(writer, http.StatusAccepted, req)
+}
+
+func (obj *Handler) dropIndex(writer http.ResponseWriter, request *http.Request) {
+	name := request.URL.Path[len("/admin/indexes/"):]
+	err := obj.db.DropIndex(request.Context(), name)
+	if err == nil || errors.Is(err, database.ErrNotFound) {
+		// A failed build may have left no index to drop.
+		obj.builds.forget(name)
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrNotFound), errors.Is(err, database.ErrInvalidIndexName):
+			obj.sendErrResponse(writer, "not found", http.StatusNotFound, err)
+		default:
+			obj.sendErrResponse(writer, "internal server error", http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	writer.WriteHeader(http.StatusNoContent)
+	obj.logger.Info("index dropped", zap.String("name", name))
+}
+
+func (obj *Handler) indexProgress(writer http.ResponseWriter, request *http.Request) {
+	progress, err := obj.db.IndexProgress(request.Context())
+	if err != nil {
+		obj.sendErrResponse(writer, "internal server error", http.StatusInternalServerError, err)
+		return
+	}
+
+	resp := make([]*IndexProgressResponse, 0, len(progress))
+	for _, item := range progress {
+		resp = append(resp, &IndexProgressResponse{
+			PID:         item.PID,
+			Index:       item.Index,
+			Phase:       item.Phase,
+			BlocksDone:  item.BlocksDone,
+			BlocksTotal: item.BlocksTotal,
+			TuplesDone:  item.TuplesDone,
+			TuplesTotal: item.TuplesTotal,
+		})
+	}
+	obj.sendJSON(writer, http.StatusOK, resp)
+}
@@ -0,0 +1,272 @@
+package httpapi
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+
+	database "github.com/atroxxxxxx/embed-store/internal/db"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultBatchSize = 500
+	// maxBatchSize keeps a flush, at 15 bind parameters per row, well under
+	// the 65535 parameters Postgres accepts in one statement.
+	maxBatchSize = 1000
+	maxLineSize  = 4 << 20
+	ndjsonType   = "application/x-ndjson"
+)
+
+var (
+	ErrInvalidBatchSize = errors.New("invalid batch size")
+	ErrNotArray         = errors.New("body is not a json array")
+	ErrTruncatedArray   = errors.New("json array is not terminated")
+)
+
+type BatchItemResult struct {
+	Line   int    `json:"line"`
+	Status string `json:"status"`
+	ID     int64  `json:"id,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+type BatchResponse struct {
+	Inserted   int                `json:"inserted"`
+	Duplicates int                `json:"duplicates"`
+	Updated    int                `json:"updated,omitempty"`
+	Failed     int                `json:"failed"`
+	Results    []*BatchItemResult `json:"results"`
+}
+
+type batchItem struct {
+	result *BatchItemResult
+	chunk  *database.Chunk
+}
+
+type batchWriter struct {
+	handler *Handler
+	ctx     context.Context
+	size    int
+	upsert  bool
+	pending []*batchItem
+	resp    BatchResponse
+}
+
+func (obj *Handler) postBatch(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodPost {
+		obj.sendErrResponse(writer, "method not allowed", http.StatusMethodNotAllowed, nil)
+		return
+	}
+
+	size := obj.cfg.BatchSize
+	if raw := request.URL.Query().Get("batch_size"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed <= 0 || parsed > maxBatchSize {
+			obj.sendErrResponse(writer, "bad request: "+ErrInvalidBatchSize.Error(), http.StatusBadRequest, err)
+			return
+		}
+		size = parsed
+	}
+
+	batch := &batchWriter{
+		handler: obj,
+		ctx:     request.Context(),
+		size:    size,
+		upsert:  request.URL.Query().Get("upsert") == "1",
+		resp:    BatchResponse{Results: make([]*BatchItemResult, 0)},
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(request.Header.Get("Content-Type"))
+	var err error
+	if mediaType == ndjsonType {
+		err = batch.readNDJSON(request.Body)
+	} else {
+		err = batch.readArray(request.Body)
+	}
+	if err != nil {
+		if errors.Is(err, bufio.ErrTooLong) {
+			obj.sendErrResponse(writer, "request entity too large: "+err.Error(), http.StatusRequestEntityTooLarge, err)
+			return
+		}
+		obj.sendErrResponse(writer, "bad request: "+err.Error(), http.StatusBadRequest, err)
+		return
+	}
+	batch.flush()
+
+	writer.Header().Set("Content-Type", "application/json")
+	writer.WriteHeader(http.StatusOK)
+	if err = json.NewEncoder(writer).Encode(batch.resp); err != nil {
+		obj.logger.Warn("encode response failed", zap.Error(err))
+	}
+	obj.logger.Info("batch processed",
+		zap.Int("inserted", batch.resp.Inserted),
+		zap.Int("duplicates", batch.resp.Duplicates),
+		zap.Int("failed", batch.resp.Failed),
+	)
+}
+
+// readNDJSON records lines that fail to decode or map and goes on; a read
+// error, such as a line over maxLineSize, rejects the rest of the request,
+// though rows flushed before that point stay written.
+func (obj *batchWriter) readNDJSON(body io.Reader) error {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+	line := 0
+	for scanner.Scan() {
+		line++
+		raw := bytes.TrimSpace(scanner.Bytes())
+		if len(raw) == 0 {
+			continue
+		}
+
+		var req Request
+		dec := json.NewDecoder(bytes.NewReader(raw))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&req); err != nil {
+			obj.fail(line, err)
+			continue
+		}
+		obj.add(line, &req)
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("line %d: %w", line+1, err)
+	}
+	return nil
+}
+
+// readArray records items that fail to decode or map and goes on; a malformed
+// or truncated array rejects the rest of the request, though rows flushed
+// before that point stay written.
+func (obj *batchWriter) readArray(body io.Reader) error {
+	dec := json.NewDecoder(body)
+	dec.DisallowUnknownFields()
+
+	token, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != '[' {
+		return ErrNotArray
+	}
+
+	for line := 1; dec.More(); line++ {
+		var req Request
+		if err = dec.Decode(&req); err != nil {
+			var syntaxErr *json.SyntaxError
+			if errors.As(err, &syntaxErr) || errors.Is(err, io.ErrUnexpectedEOF) {
+				return fmt.Errorf("item %d: %w", line, err)
+			}
+			obj.fail(line, err)
+			continue
+		}
+		obj.add(line, &req)
+	}
+	if token, err = dec.Token(); err != nil || token != json.Delim(']') {
+		return ErrTruncatedArray
+	}
+	return nil
+}
+
+func (obj *batchWriter) add(line int, req *Request) {
+	if len(req.Embedding) == 0 {
+		embedding, err := obj.handler.embed(obj.ctx, req.Text)
+		if err != nil {
+			obj.fail(line, err)
+			return
+		}
+		req.Embedding = embedding
+	}
+	chunk, err := Map(req)
+	if err != nil {
+		obj.fail(line, err)
+		return
+	}
+
+	result := &BatchItemResult{Line: line}
+	obj.resp.Results = append(obj.resp.Results, result)
+	obj.pending = append(obj.pending, &batchItem{result: result, chunk: chunk})
+	if len(obj.pending) >= obj.size {
+		obj.flush()
+	}
+}
+
+func (obj *batchWriter) fail(line int, err error) {
+	obj.resp.Failed++
+	obj.resp.Results = append(obj.resp.Results, &BatchItemResult{
+		Line:   line,
+		Status: statusError,
+		Error:  err.Error(),
+	})
+}
+
+func (obj *batchWriter) flush() {
+	if len(obj.pending) == 0 {
+		return
+	}
+
+	chunks := make([]*database.Chunk, len(obj.pending))
+	for i, item := range obj.pending {
+		chunks[i] = item.chunk
+	}
+
+	obj.handler.assignClusters(chunks...)
+	if obj.upsert {
+		obj.flushUpsert(chunks)
+		return
+	}
+
+	if _, err := obj.handler.db.InsertBatch(obj.ctx, chunks); err != nil {
+		obj.failPending(err)
+		return
+	}
+
+	for _, item := range obj.pending {
+		if item.chunk.ID != 0 {
+			item.result.Status = statusInserted
+			item.result.ID = item.chunk.ID
+			obj.resp.Inserted++
+		} else {
+			item.result.Status = statusDuplicate
+			obj.resp.Duplicates++
+		}
+	}
+	obj.pending = obj.pending[:0]
+}
+
+func (obj *batchWriter) flushUpsert(chunks []*database.Chunk) {
+	created, err := obj.handler.db.UpsertBatch(obj.ctx, chunks)
+	if err != nil {
+		obj.failPending(err)
+		return
+	}
+
+	for idx, item := range obj.pending {
+		item.result.ID = item.chunk.ID
+		if created[idx] {
+			item.result.Status = statusCreated
+			obj.resp.Inserted++
+		} else {
+			item.result.Status = statusUpdated
+			obj.resp.Updated++
+		}
+	}
+	obj.pending = obj.pending[:0]
+}
+
+func (obj *batchWriter) failPending(err error) {
+	obj.handler.logger.Error("batch write failed", zap.Error(err))
+	for _, item := range obj.pending {
+		item.result.Status = statusError
+		item.result.Error = "write failed"
+	}
+	obj.resp.Failed += len(obj.pending)
+	obj.pending = obj.pending[:0]
+}
@@ -0,0 +1,287 @@
+package httpapi
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	database "github.com/atroxxxxxx/embed-store/internal/db"
+)
+
+const (
+	defaultClusterSamples = 3
+	maxClusterSamples     = 20
+	defaultNearestLimit   = 10
+	nearestSuffix         = "/nearest"
+)
+
+var ErrInvalidClusterID = errors.New("invalid cluster id")
+
+type ClusterResponse struct {
+	ID              int32                    `json:"id"`
+	RunID           int64                    `json:"run_id"`
+	Metric          string                   `json:"metric"`
+	Label           string                   `json:"label"`
+	Size            int                      `json:"size"`
+	Inertia         float64                  `json:"inertia"`
+	MeanDistance    float64                  `json:"mean_distance"`
+	NearestCluster  *int32                   `json:"nearest_cluster,omitempty"`
+	NearestDistance float64                  `json:"nearest_distance"`
+	Silhouette      float64                  `json:"silhouette"`
+	UpdatedAt       string                   `json:"updated_at"`
+	Samples         []*ClusterSampleResponse `json:"samples,omitempty"`
+	Centroid        []float32                `json:"centroid,omitempty"`
+}
+
+type ClusterSampleResponse struct {
+	ID   int64  `json:"id"`
+	Text string `json:"text"`
+}
+
+type ClusterDetailResponse struct {
+	ClusterResponse
+	Members []*Response `json:"members"`
+}
+
+type ClusterEvaluationResponse struct {
+	ID           int64                   `json:"id"`
+	CreatedAt    string                  `json:"created_at"`
+	Metric       string                  `json:"metric"`
+	Points       int                     `json:"points"`
+	Sample       int                     `json:"silhouette_sample"`
+	RecommendedK int                     `json:"recommended_k"`
+	Scores       []*ClusterScoreResponse `json:"scores"`
+}
+
+type ClusterScoreResponse struct {
+	K          int     `json:"k"`
+	Inertia    float64 `json:"inertia"`
+	Silhouette float64 `json:"silhouette"`
+}
+
+// parseClusterID differs from parseID in accepting 0, the first k-means
+// cluster.
+func parseClusterID(path string) (int32, error) {
+	rawID, found := strings.CutPrefix(path, "/clusters/")
+	if !found || rawID == "" {
+		return 0, ErrInvalidClusterID
+	}
+	id, err := strconv.ParseInt(rawID, 10, 32)
+	if err != nil || id < 0 {
+		return 0, ErrInvalidClusterID
+	}
+	return int32(id), nil
+}
+
+func unmapCluster(info *database.ClusterInfo) ClusterResponse {
+	resp := ClusterResponse{
+		ID:              info.ID,
+		RunID:           info.RunID,
+		Metric:          string(info.Metric),
+		Label:           info.Label,
+		Size:            info.Size,
+		Inertia:         info.Inertia,
+		MeanDistance:    info.MeanDistance,
+		NearestCluster:  info.NearestCluster,
+		NearestDistance: info.NearestDistance,
+		Silhouette:      info.Silhouette,
+		UpdatedAt:       info.UpdatedAt.Format(timeLayout),
+	}
+	for _, sample := range info.Samples {
+		resp.Samples = append(resp.Samples, &ClusterSampleResponse{ID: sample.ID, Text: sample.Text})
+	}
+	if centroid := info.Centroid.Slice(); len(centroid) > 0 {
+		resp.Centroid = centroid
+	}
+	return resp
+}
+
+// queryLimit reads a positive limit parameter, clamped to maxLimit.
+func queryLimit(raw string, def, maxLimit int) (int, bool) {
+	if raw == "" {
+		return def, true
+	}
+	parsed, err := strconv.Atoi(raw)
+	if err != nil || parsed <= 0 {
+		return 0, false
+	}
+	return min(parsed, maxLimit), true
+}
+
+func (obj *Handler) clusters(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodGet {
+		obj.sendErrResponse(writer, "method not allowed", http.StatusMethodNotAllowed, nil)
+		return
+	}
+
+	samples, ok := queryLimit(request.URL.Query().Get("samples"), defaultClusterSamples, maxClusterSamples)
+	if !ok {
+		obj.sendErrResponse(writer, "bad request: invalid samples", http.StatusBadRequest, nil)
+		return
+	}
+	clusters, err := obj.db.ListClusters(request.Context(), samples)
+	if err != nil {
+		obj.sendErrResponse(writer, "internal server error", http.StatusInternalServerError, err)
+		return
+	}
+
+	resp := make([]ClusterResponse, 0, len(clusters))
+	for _, info := range clusters {
+		resp = append(resp, unmapCluster(info))
+	}
+	obj.sendJSON(writer, http.StatusOK, resp)
+}
+
+func (obj *Handler) cluster(writer http.ResponseWriter, request *http.Request) {
+	switch {
+	case request.Method == http.MethodGet && strings.HasSuffix(request.URL.Path, nearestSuffix):
+		obj.nearestChunks(writer, request)
+	case request.Method == http.MethodGet:
+		obj.getCluster(writer, request)
+	default:
+		obj.sendErrResponse(writer, "method not allowed", http.StatusMethodNotAllowed, nil)
+	}
+}
+
+func (obj *Handler) getCluster(writer http.ResponseWriter, request *http.Request) {
+	id, err := parseClusterID(request.URL.Path)
+	if err != nil {
+		obj.sendErrResponse(writer, "not found", http.StatusNotFound, err)
+		return
+	}
+	query := request.URL.Query()
+	limit, ok := queryLimit(query.Get("limit"), defaultListLimit, maxListLimit)
+	if !ok {
+		obj.sendErrResponse(writer, "bad request: invalid limit", http.StatusBadRequest, nil)
+		return
+	}
+	var after *database.ListCursor
+	if raw := query.Get("cursor"); raw != "" {
+		cursor, err := decodeCursor(raw)
+		if err != nil || cursor.Order != string(database.OrderByID) {
+			obj.sendErrResponse(writer, "bad request: "+ErrInvalidCursor.Error(), http.StatusBadRequest, err)
+			return
+		}
+		after = &database.ListCursor{ID: cursor.ID}
+	}
+
+	info, err := obj.db.ClusterByID(request.Context(), id)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			obj.sendErrResponse(writer, "not found", http.StatusNotFound, err)
+		} else {
+			obj.sendErrResponse(writer, "internal server error", http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	members, err := obj.db.ListChunks(request.Context(), memberFilter(info), database.OrderByID, after, limit)
+	if err != nil {
+		obj.sendErrResponse(writer, "internal server error", http.StatusInternalServerError, err)
+		return
+	}
+
+	resp := ClusterDetailResponse{
+		ClusterResponse: unmapCluster(info),
+		Members:         make([]*Response, 0, len(members)),
+	}
+	withEmbedding := query.Get("embed") == "1"
+	for _, chunk := range members {
+		item, err := Unmap(chunk, withEmbedding)
+		if err != nil {
+			obj.sendErrResponse(writer, "internal server error", http.StatusInternalServerError, err)
+			return
+		}
+		resp.Members = append(resp.Members, &item)
+	}
+	if len(members) == limit {
+		cursor := cursorPayload{Order: string(database.OrderByID), ID: members[len(members)-1].ID}
+		writer.Header().Set(nextCursorHeader, encodeCursor(cursor))
+	}
+	obj.sendJSON(writer, http.StatusOK, resp)
+}
+
+// nearestChunks returns the members closest to the centroid, which make
+// better representative examples than arbitrary samples.
+func (obj *Handler) nearestChunks(writer http.ResponseWriter, request *http.Request) {
+	id, err := parseClusterID(strings.TrimSuffix(request.URL.Path, nearestSuffix))
+	if err != nil {
+		obj.sendErrResponse(writer, "not found", http.StatusNotFound, err)
+		return
+	}
+	limit, ok := queryLimit(request.URL.Query().Get("limit"), defaultNearestLimit, maxSearchLimit)
+	if !ok {
+		obj.sendErrResponse(writer, "bad request: invalid limit", http.StatusBadRequest, nil)
+		return
+	}
+
+	info, err := obj.db.ClusterByID(request.Context(), id)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			obj.sendErrResponse(writer, "not found", http.StatusNotFound, err)
+		} else {
+			obj.sendErrResponse(writer, "internal server error", http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	opts := database.SearchOptions{Filter: memberFilter(info), Metric: info.Metric}
+	hits, err := obj.db.Search(request.Context(), &info.Centroid, limit, opts)
+	if err != nil {
+		obj.sendErrResponse(writer, "internal server error", http.StatusInternalServerError, err)
+		return
+	}
+	resp, err := unmapHits(hits, info.Metric, request.URL.Query().Get("embed") == "1")
+	if err != nil {
+		obj.sendErrResponse(writer, "internal server error", http.StatusInternalServerError, err)
+		return
+	}
+	obj.sendJSON(writer, http.StatusOK, resp)
+}
+
+// memberFilter selects the rows assigned to the cluster by its own run. Rows
+// left over from an older run carry ids that numbered different clusters.
+// Clusters saved before runs were tracked have no run id to match.
+func memberFilter(info *database.ClusterInfo) database.Filter {
+	filter := database.Filter{ClusterIDs: []int32{info.ID}}
+	if info.RunID != 0 {
+		filter.ClusterRunID = &info.RunID
+	}
+	return filter
+}
+
+func (obj *Handler) clusterEvaluation(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodGet {
+		obj.sendErrResponse(writer, "method not allowed", http.StatusMethodNotAllowed, nil)
+		return
+	}
+
+	evaluation, err := obj.db.LatestClusterEvaluation(request.Context())
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			obj.sendErrResponse(writer, "not found", http.StatusNotFound, err)
+		} else {
+			obj.sendErrResponse(writer, "internal server error", http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	resp := ClusterEvaluationResponse{
+		ID:           evaluation.ID,
+		CreatedAt:    evaluation.CreatedAt.Format(timeLayout),
+		Metric:       string(evaluation.Metric),
+		Points:       evaluation.Points,
+		Sample:       evaluation.Sample,
+		RecommendedK: evaluation.RecommendedK,
+		Scores:       make([]*ClusterScoreResponse, 0, len(evaluation.Scores)),
+	}
+	for _, score := range evaluation.Scores {
+		resp.Scores = append(resp.Scores, &ClusterScoreResponse{
+			K:          score.K,
+			Inertia:    score.Inertia,
+			Silhouette: score.Silhouette,
+		})
+	}
+	obj.sendJSON(writer, http.StatusOK, resp)
+}
@@ -0,0 +1,42 @@
+package httpapi
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// nextCursorHeader carries the next page token of every paged endpoint except
+// batch search, which returns it with each result.
+const nextCursorHeader = "X-Next-Cursor"
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// cursorPayload is serialized into an opaque base64 token; Distance and Seen
+// (the rows returned by earlier pages) are set for search pages, Order and
+// Time for chunk listings.
+type cursorPayload struct {
+	Distance *float64  `json:"d,omitempty"`
+	Seen     int       `json:"n,omitempty"`
+	Order    string    `json:"o,omitempty"`
+	Time     time.Time `json:"t,omitzero"`
+	ID       int64     `json:"i"`
+}
+
+func encodeCursor(payload cursorPayload) string {
+	raw, _ := json.Marshal(payload)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decodeCursor(token string) (cursorPayload, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return cursorPayload{}, ErrInvalidCursor
+	}
+	var payload cursorPayload
+	if err = json.Unmarshal(raw, &payload); err != nil {
+		return cursorPayload{}, ErrInvalidCursor
+	}
+	return payload, nil
+}
@@ -0,0 +1,137 @@
+package httpapi
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	database "github.com/atroxxxxxx/embed-store/internal/db"
+	"go.uber.org/zap"
+)
+
+func (obj *Handler) document(writer http.ResponseWriter, request *http.Request) {
+	switch {
+	case request.Method == http.MethodGet && strings.HasSuffix(request.URL.Path, similarSuffix):
+		obj.similarDocument(writer, request)
+	case request.Method == http.MethodGet:
+		obj.getDocument(writer, request)
+	case request.Method == http.MethodDelete:
+		obj.deleteDocument(writer, request)
+	default:
+		obj.sendErrResponse(writer, "method not allowed", http.StatusMethodNotAllowed, nil)
+	}
+}
+
+func (obj *Handler) deleteChunk(writer http.ResponseWriter, request *http.Request) {
+	id, err := parseID(request.URL.Path, "/chunks/")
+	if err != nil {
+		obj.sendErrResponse(writer, "not found", http.StatusNotFound, err)
+		return
+	}
+
+	soft := request.URL.Query().Get("soft") == "1"
+	if err = obj.db.DeleteChunk(request.Context(), id, soft); err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			obj.sendErrResponse(writer, "not found", http.StatusNotFound, err)
+		} else {
+			obj.sendErrResponse(writer, "internal server error", http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	obj.sendJSON(writer, http.StatusOK, DeleteResponse{Deleted: 1, Soft: soft})
+	obj.logger.Info("chunk deleted", zap.Int64("id", id), zap.Bool("soft", soft))
+}
+
+func (obj *Handler) deleteDocument(writer http.ResponseWriter, request *http.Request) {
+	docID, err := parseID(request.URL.Path, "/documents/")
+	if err != nil {
+		obj.sendErrResponse(writer, "not found", http.StatusNotFound, err)
+		return
+	}
+
+	soft := request.URL.Query().Get("soft") == "1"
+	deleted, err := obj.db.DeleteDocument(request.Context(), docID, soft)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			obj.sendErrResponse(writer, "not found", http.StatusNotFound, err)
+		} else {
+			obj.sendErrResponse(writer, "internal server error", http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	obj.sendJSON(writer, http.StatusOK, DeleteResponse{Deleted: deleted, Soft: soft})
+	obj.logger.Info("document deleted",
+		zap.Int64("doc_id", docID),
+		zap.Int64("chunks", deleted),
+		zap.Bool("soft", soft),
+	)
+}
+
+func (obj *Handler) getDocument(writer http.ResponseWriter, request *http.Request) {
+	docID, err := parseID(request.URL.Path, "/documents/")
+	if err != nil {
+		obj.sendErrResponse(writer, "not found", http.StatusNotFound, err)
+		return
+	}
+
+	query := request.URL.Query()
+	chunks, err := obj.db.DocumentChunks(request.Context(), docID, query.Get("include_deleted") == "1")
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			obj.sendErrResponse(writer, "not found", http.StatusNotFound, err)
+		} else {
+			obj.sendErrResponse(writer, "internal server error", http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	resp := UnmapDocument(chunks)
+	withText, check := query.Get("text") == "1", query.Get("check") == "1"
+	if withText || check {
+		text, gaps, overlaps := assembleDocument(chunks)
+		if withText {
+			resp.Text = &text
+		}
+		if check {
+			resp.Gaps, resp.Overlaps = gaps, overlaps
+		}
+	}
+
+	obj.sendJSON(writer, http.StatusOK, resp)
+	obj.logger.Info("document found", zap.Int64("doc_id", docID), zap.Int("chunks", len(chunks)))
+}
+
+// assembleDocument joins chunk texts ordered by chunk_no using the chunk
+// offsets (in characters): overlapping prefixes are dropped and gaps are left
+// empty, and both are reported.
+func assembleDocument(chunks []*database.Chunk) (string, []*OffsetIssue, []*OffsetIssue) {
+	var (
+		builder  strings.Builder
+		gaps     []*OffsetIssue
+		overlaps []*OffsetIssue
+		reached  int64
+	)
+	for idx, chunk := range chunks {
+		text := []rune(chunk.Text)
+		if idx > 0 {
+			prev := chunks[idx-1].Info.Number
+			switch {
+			case chunk.Info.Start > reached:
+				gaps = append(gaps, &OffsetIssue{
+					AfterChunk: prev, BeforeChunk: chunk.Info.Number, Start: reached, End: chunk.Info.Start,
+				})
+			case chunk.Info.Start < reached:
+				overlaps = append(overlaps, &OffsetIssue{
+					AfterChunk: prev, BeforeChunk: chunk.Info.Number, Start: chunk.Info.Start, End: reached,
+				})
+				skip := min(reached-chunk.Info.Start, int64(len(text)))
+				text = text[skip:]
+			}
+		}
+		builder.WriteString(string(text))
+		reached = max(reached, chunk.Info.End)
+	}
+	return builder.String(), gaps, overlaps
+}
@@ -3,6 +3,7 @@ package httpapi
 import (
 	"errors"
 	"fmt"
+	"math"
 	"strings"
 	"time"
 
@@ -26,11 +27,72 @@ type Request struct {
 	ChunkEnd   int64     `json:"chunk_end"`
 }
 
+type PatchRequest struct {
+	Title     *string   `json:"title"`
+	Author    *string   `json:"author"`
+	Text      *string   `json:"text"`
+	Score     *int32    `json:"score"`
+	Deleted   *bool     `json:"deleted"`
+	Dead      *bool     `json:"dead"`
+	Embedding []float32 `json:"embedding"`
+}
+
+// SearchRequest describes one vector or hybrid search. NProbe only returns
+// rows of the nprobe clusters nearest to the query: rows not assigned to a
+// cluster yet are left out, and it is ignored until a clustering run has
+// stored centroids. In hybrid mode EfSearch, Profile and Exact tune only the
+// vector half; the lexical half is always an exact full-text match.
 type SearchRequest struct {
-	Embedding        []float32 `json:"embedding"`
-	Limit            int       `json:"limit"`
-	ClusterIDs       []int32   `json:"cluster_ids"`
-	IncludeEmbedding bool      `json:"include_embedding"`
+	Embedding        []float32         `json:"embedding"`
+	Query            string            `json:"query"`
+	Limit            int               `json:"limit"`
+	Cursor           string            `json:"cursor"`
+	ClusterIDs       []int32           `json:"cluster_ids"`
+	Filter           *SearchFilter     `json:"filter"`
+	Metric           string            `json:"metric"`
+	MaxDistance      *float64          `json:"max_distance"`
+	GroupBy          string            `json:"group_by"`
+	Aggregate        string            `json:"aggregate"`
+	IncludeChunks    bool              `json:"include_chunks"`
+	Mode             string            `json:"mode"`
+	Hybrid           *HybridOptions    `json:"hybrid"`
+	Diversify        *DiversifyOptions `json:"diversify"`
+	EfSearch         int               `json:"ef_search"`
+	Profile          string            `json:"profile"`
+	Exact            bool              `json:"exact"`
+	NProbe           int               `json:"nprobe"`
+	IncludeEmbedding bool              `json:"include_embedding"`
+	IncludeDeleted   bool              `json:"include_deleted"`
+
+	excludeIDs    []int64
+	excludeDocIDs []int64
+}
+
+type SearchFilter struct {
+	Types    []string   `json:"types"`
+	Authors  []string   `json:"authors"`
+	Time     *TimeRange `json:"time"`
+	MinScore *int32     `json:"min_score"`
+	DocIDs   []int64    `json:"doc_ids"`
+	Deleted  *bool      `json:"deleted"`
+	Dead     *bool      `json:"dead"`
+}
+
+type HybridOptions struct {
+	VectorWeight *float64 `json:"vector_weight"`
+	TextWeight   *float64 `json:"text_weight"`
+	RRFK         float64  `json:"rrf_k"`
+	FetchK       int      `json:"fetch_k"`
+}
+
+type DiversifyOptions struct {
+	Lambda *float64 `json:"lambda"`
+	FetchK int      `json:"fetch_k"`
+}
+
+type TimeRange struct {
+	From string `json:"from"`
+	To   string `json:"to"`
 }
 
 type Response struct {
@@ -49,20 +111,104 @@ type Response struct {
 	Info      db.Metadata `json:"chunk_metadata"`
 }
 
+type WriteResponse struct {
+	Response
+	Status string `json:"status"`
+}
+
+type DocumentResponse struct {
+	DocID    int64            `json:"doc_id"`
+	Title    *string          `json:"title,omitempty"`
+	Author   *string          `json:"author,omitempty"`
+	Time     string           `json:"time"`
+	Type     string           `json:"type"`
+	Score    int32            `json:"score"`
+	Deleted  bool             `json:"deleted"`
+	Dead     bool             `json:"dead"`
+	Chunks   []*DocumentChunk `json:"chunks"`
+	Text     *string          `json:"text,omitempty"`
+	Gaps     []*OffsetIssue   `json:"gaps,omitempty"`
+	Overlaps []*OffsetIssue   `json:"overlaps,omitempty"`
+}
+
+type DocumentChunk struct {
+	ID        int64  `json:"id"`
+	ChunkNo   int32  `json:"chunk_no"`
+	Start     int64  `json:"chunk_start"`
+	End       int64  `json:"chunk_end"`
+	Text      string `json:"text"`
+	Deleted   bool   `json:"deleted"`
+	ClusterID int32  `json:"cluster_id"`
+}
+
+type OffsetIssue struct {
+	AfterChunk  int32 `json:"after_chunk"`
+	BeforeChunk int32 `json:"before_chunk"`
+	Start       int64 `json:"start"`
+	End         int64 `json:"end"`
+}
+
+type BatchSearchResult struct {
+	Results    any    `json:"results,omitempty"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	Status     int    `json:"status"`
+	Error      string `json:"error,omitempty"`
+}
+
+type DeleteResponse struct {
+	Deleted int64 `json:"deleted"`
+	Soft    bool  `json:"soft"`
+}
+
+type HitResponse struct {
+	Response
+	Distance   float64  `json:"distance"`
+	Similarity *float64 `json:"similarity,omitempty"`
+	Relevance  *float64 `json:"relevance,omitempty"`
+}
+
+type DocumentHitResponse struct {
+	DocID         int64          `json:"doc_id"`
+	Distance      float64        `json:"distance"`
+	Similarity    *float64       `json:"similarity,omitempty"`
+	MatchedChunks int            `json:"matched_chunks"`
+	BestChunk     HitResponse    `json:"best_chunk"`
+	Chunks        []*HitResponse `json:"chunks,omitempty"`
+}
+
 var (
 	ErrInvalidType         = errors.New("undefined type")
 	ErrInvalidEmbeddingLen = errors.New("invalid embedding length")
 	ErrChunkNull           = errors.New("chunk is null")
 	ErrRequestNull         = errors.New("request is null")
+	ErrInvalidTimeRange    = errors.New("invalid time range")
+	ErrEmptyAuthor         = errors.New("empty author")
+	ErrInvalidMaxDistance  = errors.New("invalid max distance")
+	ErrInvalidGroupBy      = errors.New("invalid group_by")
+	ErrInvalidMode         = errors.New("invalid search mode")
+	ErrInvalidWeight       = errors.New("invalid hybrid weight")
+	ErrQueryRequired       = errors.New("query text is required")
+	ErrUnsupportedOption   = errors.New("option is not supported in this mode")
+	ErrInvalidLambda       = errors.New("lambda must be within [0, 1]")
+	ErrInvalidEfSearch     = errors.New("invalid ef_search")
+	ErrInvalidProfile      = errors.New("profile must be fast, balanced or accurate")
+	ErrInvalidNProbe       = errors.New("invalid nprobe")
 )
 
 const (
 	timeLayout = time.RFC3339
-	story      = "story"
-	comment    = "comment"
-	poll       = "poll"
-	pollopt    = "pollopt"
-	job        = "job"
+
+	statusCreated   = "created"
+	statusUpdated   = "updated"
+	statusInserted  = "inserted"
+	statusDuplicate = "duplicate"
+	statusError     = "error"
+
+	story   = "story"
+	comment = "comment"
+	poll    = "poll"
+	pollopt = "pollopt"
+	job     = "job"
 )
 
 func Map(request *Request) (*db.Chunk, error) {
@@ -75,9 +221,9 @@ func Map(request *Request) (*db.Chunk, error) {
 		return nil, fmt.Errorf("time parsing: %w", err)
 	}
 
-	requestType := strings.TrimSpace(strings.ToLower(request.Type))
-	if requestType != story && requestType != comment && requestType != poll && requestType != pollopt && requestType != job {
-		return nil, ErrInvalidType
+	requestType, err := normalizeType(request.Type)
+	if err != nil {
+		return nil, err
 	}
 
 	if len(request.Embedding) != db.VectorSize {
@@ -103,6 +249,85 @@ func Map(request *Request) (*db.Chunk, error) {
 	}, nil
 }
 
+func MapPatch(request *PatchRequest) (*db.ChunkPatch, error) {
+	if request == nil {
+		return nil, ErrRequestNull
+	}
+
+	patch := &db.ChunkPatch{
+		Title:   request.Title,
+		Author:  request.Author,
+		Text:    request.Text,
+		Score:   request.Score,
+		Deleted: request.Deleted,
+		Dead:    request.Dead,
+	}
+	if request.Embedding != nil {
+		if len(request.Embedding) != db.VectorSize {
+			return nil, ErrInvalidEmbeddingLen
+		}
+		vec := pgvector.NewVector(request.Embedding)
+		patch.Embedding = &vec
+	}
+	return patch, nil
+}
+
+func MapFilter(filter *SearchFilter) (db.Filter, error) {
+	if filter == nil {
+		return db.Filter{}, nil
+	}
+
+	out := db.Filter{
+		MinScore: filter.MinScore,
+		DocIDs:   filter.DocIDs,
+		Deleted:  filter.Deleted,
+		Dead:     filter.Dead,
+	}
+	for _, filterType := range filter.Types {
+		normalized, err := normalizeType(filterType)
+		if err != nil {
+			return db.Filter{}, fmt.Errorf("%w: %q", err, filterType)
+		}
+		out.Types = append(out.Types, normalized)
+	}
+	for _, author := range filter.Authors {
+		if strings.TrimSpace(author) == "" {
+			return db.Filter{}, ErrEmptyAuthor
+		}
+		out.Authors = append(out.Authors, author)
+	}
+	if filter.Time != nil {
+		if filter.Time.From != "" {
+			from, err := time.Parse(timeLayout, filter.Time.From)
+			if err != nil {
+				return db.Filter{}, fmt.Errorf("time.from parsing: %w", err)
+			}
+			out.From = &from
+		}
+		if filter.Time.To != "" {
+			to, err := time.Parse(timeLayout, filter.Time.To)
+			if err != nil {
+				return db.Filter{}, fmt.Errorf("time.to parsing: %w", err)
+			}
+			out.To = &to
+		}
+		if out.From != nil && out.To != nil && out.From.After(*out.To) {
+			return db.Filter{}, ErrInvalidTimeRange
+		}
+	}
+	return out, nil
+}
+
+func normalizeType(value string) (string, error) {
+	normalized := strings.TrimSpace(strings.ToLower(value))
+	switch normalized {
+	case story, comment, poll, pollopt, job:
+		return normalized, nil
+	default:
+		return "", ErrInvalidType
+	}
+}
+
 func Unmap(chunk *db.Chunk, withEmbedding bool) (Response, error) {
 	if chunk == nil {
 		return Response{}, ErrChunkNull
@@ -134,3 +359,67 @@ func Unmap(chunk *db.Chunk, withEmbedding bool) (Response, error) {
 		Info:      chunk.Info,
 	}, nil
 }
+
+func UnmapHit(hit *db.SearchHit, metric db.Metric, withEmbedding bool) (HitResponse, error) {
+	if hit == nil {
+		return HitResponse{}, ErrChunkNull
+	}
+	resp, err := Unmap(&hit.Chunk, withEmbedding)
+	if err != nil {
+		return HitResponse{}, err
+	}
+
+	out := HitResponse{Response: resp, Distance: hit.Distance}
+	if similarity, ok := metric.Similarity(hit.Distance); ok {
+		out.Similarity = &similarity
+	}
+	return out, nil
+}
+
+func validateMaxDistance(maxDistance *float64, metric db.Metric) error {
+	if maxDistance == nil {
+		return nil
+	}
+	if math.IsNaN(*maxDistance) || math.IsInf(*maxDistance, 0) {
+		return ErrInvalidMaxDistance
+	}
+	if metric != db.MetricInnerProduct && *maxDistance < 0 {
+		return ErrInvalidMaxDistance
+	}
+	return nil
+}
+
+func UnmapDocument(chunks []*db.Chunk) DocumentResponse {
+	if len(chunks) == 0 {
+		return DocumentResponse{Chunks: []*DocumentChunk{}}
+	}
+
+	head := chunks[0]
+	resp := DocumentResponse{
+		DocID:   head.DocID,
+		Title:   head.Title,
+		Author:  head.Author,
+		Time:    head.Time.Format(timeLayout),
+		Type:    head.Type,
+		Score:   head.Score,
+		Deleted: head.Deleted,
+		Dead:    head.Dead,
+		Chunks:  make([]*DocumentChunk, 0, len(chunks)),
+	}
+	for _, chunk := range chunks {
+		var clusterID int32 = -1
+		if chunk.ClusterID != nil {
+			clusterID = *chunk.ClusterID
+		}
+		resp.Chunks = append(resp.Chunks, &DocumentChunk{
+			ID:        chunk.ID,
+			ChunkNo:   chunk.Info.Number,
+			Start:     chunk.Info.Start,
+			End:       chunk.Info.End,
+			Text:      chunk.Text,
+			Deleted:   chunk.Deleted,
+			ClusterID: clusterID,
+		})
+	}
+	return resp
+}
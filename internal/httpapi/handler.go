@@ -3,44 +3,149 @@ package httpapi
 import (
 	"context"
 	"errors"
+	"fmt"
 	"net/http"
+	"strings"
 
 	database "github.com/atroxxxxxx/embed-store/internal/db"
 	"github.com/pgvector/pgvector-go"
 	"go.uber.org/zap"
 )
 
+type IndexRepo interface {
+	ListIndexes(ctx context.Context) ([]*database.IndexInfo, error)
+	CreateIndex(ctx context.Context, spec database.IndexSpec) error
+	DropIndex(ctx context.Context, name string) error
+	IndexProgress(ctx context.Context) ([]*database.IndexProgress, error)
+}
+
+type ClusterRepo interface {
+	ListClusters(ctx context.Context, samples int) ([]*database.ClusterInfo, error)
+	ClusterByID(ctx context.Context, id int32) (*database.ClusterInfo, error)
+	LatestClusterEvaluation(ctx context.Context) (*database.ClusterEvaluation, error)
+}
+
 type Repo interface {
+	IndexRepo
+	ClusterRepo
 	InsertChunk(ctx context.Context, chunk *database.Chunk) (int64, error)
+	InsertBatch(ctx context.Context, batch []*database.Chunk) (int64, error)
+	UpsertChunk(ctx context.Context, chunk *database.Chunk) (bool, error)
+	UpsertBatch(ctx context.Context, batch []*database.Chunk) ([]bool, error)
+	UpdateChunk(ctx context.Context, id int64, chunk *database.Chunk) error
+	PatchChunk(ctx context.Context, id int64, patch *database.ChunkPatch) (*database.Chunk, error)
+	DeleteChunk(ctx context.Context, id int64, soft bool) error
+	DeleteDocument(ctx context.Context, docID int64, soft bool) (int64, error)
+	DocumentChunks(ctx context.Context, docID int64, includeDeleted bool) ([]*database.Chunk, error)
+	DocumentEmbedding(ctx context.Context, docID int64) (pgvector.Vector, error)
+	ListChunks(
+		ctx context.Context, filter database.Filter, order database.ListOrder, after *database.ListCursor, limit int,
+	) ([]*database.Chunk, error)
 	ChunkByID(ctx context.Context, id int64) (*database.Chunk, error)
-	Search(ctx context.Context, vec *pgvector.Vector, limit int) ([]*database.Chunk, error)
-	SearchInClusters(ctx context.Context, vec *pgvector.Vector, clusterIDs []int32, limit int) ([]*database.Chunk, error)
+	Search(ctx context.Context, vec *pgvector.Vector, limit int, opts database.SearchOptions) ([]*database.SearchHit, error)
+	SearchInClusters(
+		ctx context.Context, vec *pgvector.Vector, clusterIDs []int32, limit int, opts database.SearchOptions,
+	) ([]*database.SearchHit, error)
+	LexicalSearch(
+		ctx context.Context, vec *pgvector.Vector, query string, limit int, opts database.SearchOptions,
+	) ([]*database.SearchHit, error)
+}
+
+type Embedder interface {
+	Embed(ctx context.Context, text string) (pgvector.Vector, error)
+}
+
+// Assigner sets cluster ids on chunks before they are inserted.
+type Assigner interface {
+	AssignChunks(chunks ...*database.Chunk)
+}
+
+type Config struct {
+	BatchSize     int
+	SearchWorkers int
+	EfSearch      int
+	MaxEfSearch   int
+	// AdminToken enables the /admin routes behind a bearer token.
+	AdminToken string
 }
 
 type Handler struct {
-	db     Repo
-	logger *zap.Logger
+	db       Repo
+	embedder Embedder
+	assigner Assigner
+	cfg      Config
+	logger   *zap.Logger
+	builds   *indexBuilds
 }
 
 var (
-	ErrNullArgs = errors.New("null constructor arguments")
+	ErrNullArgs         = errors.New("null constructor arguments")
+	ErrEmbedderDisabled = errors.New("embedder is not configured")
+	ErrEmptyText        = errors.New("text is empty")
 )
 
-func New(db Repo, logger *zap.Logger) (*Handler, error) {
+// New builds the handler; embedder may be nil, in which case requests without
+// an embedding are rejected, and assigner may be nil to leave cluster ids unset.
+func New(db Repo, embedder Embedder, assigner Assigner, cfg Config, logger *zap.Logger) (*Handler, error) {
 	if db == nil || logger == nil {
 		return nil, ErrNullArgs
 	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = defaultBatchSize
+	}
+	cfg.BatchSize = min(cfg.BatchSize, maxBatchSize)
+	if cfg.SearchWorkers <= 0 {
+		cfg.SearchWorkers = defaultSearchWorkers
+	}
+	if cfg.MaxEfSearch <= 0 {
+		cfg.MaxEfSearch = defaultMaxEfSearch
+	}
+	cfg.EfSearch = min(cfg.EfSearch, cfg.MaxEfSearch)
 
 	return &Handler{
-		db:     db,
-		logger: logger,
+		db:       db,
+		embedder: embedder,
+		assigner: assigner,
+		cfg:      cfg,
+		logger:   logger,
+		builds:   newIndexBuilds(),
 	}, nil
 }
 
+func (obj *Handler) embed(ctx context.Context, text string) ([]float32, error) {
+	if obj.embedder == nil {
+		return nil, badRequest(ErrEmbedderDisabled)
+	}
+	if strings.TrimSpace(text) == "" {
+		return nil, badRequest(ErrEmptyText)
+	}
+	vec, err := obj.embedder.Embed(ctx, text)
+	if err != nil {
+		return nil, fmt.Errorf("embed: %w", err)
+	}
+	return vec.Slice(), nil
+}
+
+func (obj *Handler) assignClusters(chunks ...*database.Chunk) {
+	if obj.assigner != nil {
+		obj.assigner.AssignChunks(chunks...)
+	}
+}
+
 func (obj *Handler) Routes() *http.ServeMux {
 	mux := http.NewServeMux()
-	mux.HandleFunc("/chunks", obj.post)
-	mux.HandleFunc("/chunks/", obj.get)
+	mux.HandleFunc("/chunks", obj.chunks)
+	mux.HandleFunc("/chunks:batch", obj.postBatch)
+	mux.HandleFunc("/chunks/", obj.chunk)
+	mux.HandleFunc("/documents/", obj.document)
 	mux.HandleFunc("/search", obj.search)
+	mux.HandleFunc("/search:batch", obj.searchBatch)
+	mux.HandleFunc("/clusters", obj.clusters)
+	mux.HandleFunc("/clusters/", obj.cluster)
+	mux.HandleFunc("/clusters/evaluation", obj.clusterEvaluation)
+	if obj.cfg.AdminToken != "" {
+		mux.HandleFunc("/admin/indexes", obj.requireAdmin(obj.indexes))
+		mux.HandleFunc("/admin/indexes/", obj.requireAdmin(obj.index))
+	}
 	return mux
 }
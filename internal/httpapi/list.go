@@ -0,0 +1,83 @@
+package httpapi
+
+import (
+	"errors"
+	"net/http"
+
+	database "github.com/atroxxxxxx/embed-store/internal/db"
+)
+
+const (
+	defaultListLimit = 50
+	maxListLimit     = 500
+)
+
+var ErrInvalidOrder = errors.New("order must be id or time")
+
+func (obj *Handler) list(writer http.ResponseWriter, request *http.Request) {
+	query := request.URL.Query()
+
+	limit, ok := queryLimit(query.Get("limit"), defaultListLimit, maxListLimit)
+	if !ok {
+		obj.sendErrResponse(writer, "bad request: invalid limit", http.StatusBadRequest, nil)
+		return
+	}
+
+	order := database.ListOrder(query.Get("order"))
+	if order == "" {
+		order = database.OrderByID
+	}
+	if order != database.OrderByID && order != database.OrderByTime {
+		obj.sendErrResponse(writer, "bad request: "+ErrInvalidOrder.Error(), http.StatusBadRequest, ErrInvalidOrder)
+		return
+	}
+
+	var after *database.ListCursor
+	if raw := query.Get("cursor"); raw != "" {
+		cursor, err := decodeCursor(raw)
+		if err != nil || cursor.Order != string(order) {
+			obj.sendErrResponse(writer, "bad request: "+ErrInvalidCursor.Error(), http.StatusBadRequest, err)
+			return
+		}
+		after = &database.ListCursor{Time: cursor.Time, ID: cursor.ID}
+	}
+
+	rawFilter, err := filterFromQuery(query)
+	if err != nil {
+		obj.sendErrResponse(writer, "bad request: "+err.Error(), http.StatusBadRequest, err)
+		return
+	}
+	filter, err := MapFilter(rawFilter)
+	if err != nil {
+		obj.sendErrResponse(writer, "bad request: "+err.Error(), http.StatusBadRequest, err)
+		return
+	}
+	filter.IncludeDeleted = query.Get("include_deleted") == "1"
+
+	chunks, err := obj.db.ListChunks(request.Context(), filter, order, after, limit)
+	if err != nil {
+		obj.sendErrResponse(writer, "internal server error", http.StatusInternalServerError, err)
+		return
+	}
+
+	resp := make([]*Response, 0, len(chunks))
+	withEmbedding := query.Get("embed") == "1"
+	for _, chunk := range chunks {
+		item, err := Unmap(chunk, withEmbedding)
+		if err != nil {
+			obj.sendErrResponse(writer, "internal server error", http.StatusInternalServerError, err)
+			return
+		}
+		resp = append(resp, &item)
+	}
+	if len(chunks) == limit {
+		last := chunks[len(chunks)-1]
+		payload := cursorPayload{Order: string(order), ID: last.ID}
+		if order == database.OrderByTime {
+			payload.Time = last.Time
+		}
+		writer.Header().Set(nextCursorHeader, encodeCursor(payload))
+	}
+
+	obj.sendJSON(writer, http.StatusOK, resp)
+}
@@ -10,7 +10,6 @@ import (
 	"strings"
 
 	database "github.com/atroxxxxxx/embed-store/internal/db"
-	"github.com/pgvector/pgvector-go"
 	"go.uber.org/zap"
 )
 
@@ -26,6 +25,42 @@ func (obj *Handler) sendErrResponse(writer http.ResponseWriter, message string,
 	_, _ = writer.Write([]byte(message))
 }
 
+func (obj *Handler) sendJSON(writer http.ResponseWriter, code int, body any) {
+	writer.Header().Set("Content-Type", "application/json")
+	writer.WriteHeader(code)
+	if err := json.NewEncoder(writer).Encode(body); err != nil {
+		obj.logger.Warn("encode response failed", zap.Error(err))
+	}
+}
+
+func (obj *Handler) chunks(writer http.ResponseWriter, request *http.Request) {
+	switch request.Method {
+	case http.MethodGet:
+		obj.list(writer, request)
+	case http.MethodPost:
+		obj.post(writer, request)
+	default:
+		obj.sendErrResponse(writer, "method not allowed", http.StatusMethodNotAllowed, nil)
+	}
+}
+
+func (obj *Handler) chunk(writer http.ResponseWriter, request *http.Request) {
+	switch {
+	case request.Method == http.MethodGet && strings.HasSuffix(request.URL.Path, similarSuffix):
+		obj.similarChunk(writer, request)
+	case request.Method == http.MethodGet:
+		obj.get(writer, request)
+	case request.Method == http.MethodPut:
+		obj.put(writer, request)
+	case request.Method == http.MethodPatch:
+		obj.patch(writer, request)
+	case request.Method == http.MethodDelete:
+		obj.deleteChunk(writer, request)
+	default:
+		obj.sendErrResponse(writer, "method not allowed", http.StatusMethodNotAllowed, nil)
+	}
+}
+
 func (obj *Handler) post(writer http.ResponseWriter, request *http.Request) {
 	if request.Method != http.MethodPost {
 		obj.sendErrResponse(writer, "method not allowed", http.StatusMethodNotAllowed, nil)
@@ -41,29 +76,49 @@ func (obj *Handler) post(writer http.ResponseWriter, request *http.Request) {
 		obj.sendErrResponse(writer, "bad request", http.StatusBadRequest, err)
 		return
 	}
+	if len(req.Embedding) == 0 {
+		if req.Embedding, err = obj.embed(request.Context(), req.Text); err != nil {
+			obj.sendRequestErr(writer, err)
+			return
+		}
+	}
 	chunk, err := Map(&req)
 	if err != nil {
 		obj.sendErrResponse(writer, "bad request", http.StatusBadRequest, err)
 		return
 	}
-	id, err := obj.db.InsertChunk(request.Context(), chunk)
-	if err != nil {
-		if errors.Is(err, database.ErrDuplicateKey) {
-			obj.sendErrResponse(writer, "conflict", http.StatusPaymentRequired, err)
-		} else {
+
+	code, status := http.StatusCreated, statusCreated
+	obj.assignClusters(chunk)
+	if request.URL.Query().Get("upsert") == "1" {
+		created, err := obj.db.UpsertChunk(request.Context(), chunk)
+		if err != nil {
 			obj.sendErrResponse(writer, "internal server error", http.StatusInternalServerError, err)
+			return
+		}
+		if !created {
+			code, status = http.StatusOK, statusUpdated
+		}
+	} else {
+		if _, err = obj.db.InsertChunk(request.Context(), chunk); err != nil {
+			if errors.Is(err, database.ErrDuplicateKey) {
+				obj.sendErrResponse(writer, "conflict", http.StatusPaymentRequired, err)
+			} else {
+				obj.sendErrResponse(writer, "internal server error", http.StatusInternalServerError, err)
+			}
+			return
 		}
-		return
 	}
-	obj.logger.Debug("successfully inserted", zap.Int64("id", id))
+	id := chunk.ID
+	obj.logger.Debug("successfully written", zap.Int64("id", id), zap.String("status", status))
 	resp, err := Unmap(chunk, false)
 	if err != nil {
 		obj.sendErrResponse(writer, "internal server error", http.StatusInternalServerError, err)
 		return
 	}
 	writer.Header().Set("Content-Type", "application/json")
-	writer.WriteHeader(http.StatusCreated)
-	err = json.NewEncoder(writer).Encode(resp)
+	writer.WriteHeader(code)
+	err = json.NewEncoder(writer).Encode(WriteResponse{Response: resp, Status: status})
 	if err != nil {
 		obj.logger.Warn("encode internal server error",
 			zap.Int("code", http.StatusInternalServerError),
@@ -122,51 +177,23 @@ func (obj *Handler) search(writer http.ResponseWriter, request *http.Request) {
 	}
 
 	var req SearchRequest
-	if err := json.NewDecoder(request.Body).Decode(&req); err != nil {
-		obj.sendErrResponse(writer, "bad request", http.StatusBadRequest, err)
-		return
-	}
-	if len(req.Embedding) != database.VectorSize {
-		obj.sendErrResponse(writer,
-			"bad request: invalid embedding length", http.StatusBadRequest, ErrInvalidEmbeddingLen,
-		)
+	dec := json.NewDecoder(request.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		obj.sendErrResponse(writer, "bad request: "+err.Error(), http.StatusBadRequest, err)
 		return
 	}
-	if req.Limit <= 0 {
-		req.Limit = 4
-	} else if req.Limit > 100 {
-		req.Limit = 100
-	}
-
-	var (
-		chunks []*database.Chunk
-		err    error
-	)
-
-	vec := pgvector.NewVector(req.Embedding)
-	if len(req.ClusterIDs) > 0 {
-		chunks, err = obj.db.SearchInClusters(request.Context(), &vec, req.ClusterIDs, req.Limit)
-	} else {
-		chunks, err = obj.db.Search(request.Context(), &vec, req.Limit)
-	}
+	result, err := obj.runSearch(request.Context(), &req)
 	if err != nil {
-		obj.sendErrResponse(writer, "internal server error", http.StatusInternalServerError, err)
+		obj.sendRequestErr(writer, err)
 		return
 	}
+	obj.sendSearchResult(writer, result)
+}
 
-	responses := make([]*Response, 0, len(chunks))
-	for _, chunk := range chunks {
-		resp, err := Unmap(chunk, req.IncludeEmbedding)
-		if err != nil {
-			obj.sendErrResponse(writer, "internal server error", http.StatusInternalServerError, err)
-		}
-		responses = append(responses, &resp)
-	}
-
-	writer.Header().Set("Content-Type", "application/json")
-	writer.WriteHeader(http.StatusOK)
-
-	if err = json.NewEncoder(writer).Encode(responses); err != nil {
-		obj.logger.Warn("encode response failed", zap.Error(err))
+func (obj *Handler) sendSearchResult(writer http.ResponseWriter, result *searchResult) {
+	if result.nextCursor != "" {
+		writer.Header().Set(nextCursorHeader, result.nextCursor)
 	}
+	obj.sendJSON(writer, http.StatusOK, result.results)
 }
@@ -0,0 +1,462 @@
+package httpapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	database "github.com/atroxxxxxx/embed-store/internal/db"
+	"github.com/atroxxxxxx/embed-store/internal/search"
+	"github.com/pgvector/pgvector-go"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultSearchLimit = 4
+	maxSearchLimit     = 100
+	groupByDoc         = "doc"
+	groupFetchFactor   = 10
+	modeVector         = "vector"
+	modeHybrid         = "hybrid"
+	hybridFetchFactor  = 4
+	mmrFetchFactor     = 4
+	defaultMMRLambda   = 0.5
+	maxFetch           = 1000
+
+	defaultSearchWorkers = 8
+	maxBatchQueries      = 100
+
+	defaultMaxEfSearch = 400
+	pgvectorEfSearch   = 40 // hnsw.ef_search when neither the request nor the server sets it
+	profileFast        = "fast"
+	profileBalanced    = "balanced"
+	profileAccurate    = "accurate"
+	fastEfSearch       = 20
+	balancedEfSearch   = 100
+	accurateEfSearch   = 400
+
+	maxNProbe = 256
+)
+
+type requestError struct {
+	err error
+}
+
+func (obj *requestError) Error() string {
+	return obj.err.Error()
+}
+
+func (obj *requestError) Unwrap() error {
+	return obj.err
+}
+
+func badRequest(err error) error {
+	return &requestError{err: err}
+}
+
+func (obj *Handler) sendRequestErr(writer http.ResponseWriter, err error) {
+	var reqErr *requestError
+	if errors.As(err, &reqErr) {
+		obj.sendErrResponse(writer, "bad request: "+err.Error(), http.StatusBadRequest, err)
+		return
+	}
+	obj.sendErrResponse(writer, "internal server error", http.StatusInternalServerError, err)
+}
+
+type searchResult struct {
+	results    any
+	nextCursor string
+}
+
+func (obj *Handler) runSearch(ctx context.Context, req *SearchRequest) (*searchResult, error) {
+	mode := strings.TrimSpace(strings.ToLower(req.Mode))
+	if mode == "" {
+		mode = modeVector
+	}
+	if mode != modeVector && mode != modeHybrid {
+		return nil, badRequest(ErrInvalidMode)
+	}
+	if mode == modeHybrid && strings.TrimSpace(req.Query) == "" {
+		return nil, badRequest(ErrQueryRequired)
+	}
+
+	if len(req.Embedding) == 0 && strings.TrimSpace(req.Query) != "" {
+		embedding, err := obj.embed(ctx, req.Query)
+		if err != nil {
+			return nil, err
+		}
+		req.Embedding = embedding
+	}
+	if len(req.Embedding) != database.VectorSize {
+		return nil, badRequest(ErrInvalidEmbeddingLen)
+	}
+	if req.Limit <= 0 {
+		req.Limit = defaultSearchLimit
+	} else if req.Limit > maxSearchLimit {
+		req.Limit = maxSearchLimit
+	}
+
+	filter, err := MapFilter(req.Filter)
+	if err != nil {
+		return nil, badRequest(err)
+	}
+	filter.IncludeDeleted = req.IncludeDeleted
+	filter.ExcludeIDs = req.excludeIDs
+	filter.ExcludeDocIDs = req.excludeDocIDs
+	metric, err := database.ParseMetric(req.Metric)
+	if err != nil {
+		return nil, badRequest(err)
+	}
+	if err = validateMaxDistance(req.MaxDistance, metric); err != nil {
+		return nil, badRequest(err)
+	}
+
+	groupBy := strings.TrimSpace(strings.ToLower(req.GroupBy))
+	if groupBy != "" && groupBy != groupByDoc {
+		return nil, badRequest(ErrInvalidGroupBy)
+	}
+	aggregate, err := search.ParseAggregate(req.Aggregate)
+	if err != nil {
+		return nil, badRequest(err)
+	}
+
+	efSearch, err := obj.efSearch(req)
+	if err != nil {
+		return nil, badRequest(err)
+	}
+
+	if req.NProbe != 0 {
+		if req.NProbe < 0 || req.NProbe > maxNProbe {
+			return nil, badRequest(fmt.Errorf("%w: must be within [1, %d]", ErrInvalidNProbe, maxNProbe))
+		}
+		if mode == modeHybrid || len(req.ClusterIDs) > 0 {
+			return nil, badRequest(ErrUnsupportedOption)
+		}
+	}
+
+	opts := database.SearchOptions{
+		Filter:      filter,
+		Metric:      metric,
+		MaxDistance: req.MaxDistance,
+		EfSearch:    efSearch,
+		NProbe:      req.NProbe,
+		Exact:       req.Exact,
+	}
+	vec := pgvector.NewVector(req.Embedding)
+
+	var seen int
+	if req.Cursor != "" {
+		if mode == modeHybrid || groupBy != "" || req.Diversify != nil {
+			return nil, badRequest(ErrUnsupportedOption)
+		}
+		cursor, err := decodeCursor(req.Cursor)
+		if err != nil || cursor.Distance == nil {
+			return nil, badRequest(ErrInvalidCursor)
+		}
+		opts.After = &database.SearchCursor{Distance: *cursor.Distance, ID: cursor.ID}
+		seen = cursor.Seen
+		if !req.Exact {
+			opts.EfSearch = cursorEfSearch(opts.EfSearch, seen, req.Limit, obj.cfg.MaxEfSearch)
+		}
+	}
+
+	if mode == modeHybrid {
+		if groupBy != "" || len(req.ClusterIDs) > 0 || req.Diversify != nil {
+			return nil, badRequest(ErrUnsupportedOption)
+		}
+		results, err := obj.hybridSearch(ctx, req, &vec, opts)
+		if err != nil {
+			return nil, err
+		}
+		return &searchResult{results: results}, nil
+	}
+	if req.Hybrid != nil {
+		return nil, badRequest(ErrUnsupportedOption)
+	}
+
+	if req.Diversify != nil {
+		if groupBy != "" {
+			return nil, badRequest(ErrUnsupportedOption)
+		}
+		results, err := obj.diversifiedSearch(ctx, req, &vec, opts)
+		if err != nil {
+			return nil, err
+		}
+		return &searchResult{results: results}, nil
+	}
+
+	fetch := req.Limit
+	if groupBy == groupByDoc {
+		fetch = min(req.Limit*groupFetchFactor, maxFetch)
+	}
+	hits, err := obj.fetchHits(ctx, &vec, req.ClusterIDs, fetch, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if groupBy == groupByDoc {
+		results, err := unmapDocuments(search.GroupByDoc(hits, aggregate, req.Limit), metric, req)
+		if err != nil {
+			return nil, err
+		}
+		return &searchResult{results: results}, nil
+	}
+
+	results, err := unmapHits(hits, metric, req.IncludeEmbedding)
+	if err != nil {
+		return nil, err
+	}
+	out := &searchResult{results: results}
+	if len(hits) == req.Limit {
+		last := hits[len(hits)-1]
+		out.nextCursor = encodeCursor(cursorPayload{Distance: &last.Distance, Seen: seen + len(hits), ID: last.ID})
+	}
+	return out, nil
+}
+
+// efSearch resolves the hnsw.ef_search value for a request: an explicit
+// ef_search wins over a profile, and both are bounded by the server maximum.
+func (obj *Handler) efSearch(req *SearchRequest) (int, error) {
+	if req.EfSearch != 0 && req.Profile != "" {
+		return 0, ErrUnsupportedOption
+	}
+	if req.Exact {
+		// Index tuning is meaningless for a sequential scan.
+		if req.EfSearch != 0 || req.Profile != "" {
+			return 0, ErrUnsupportedOption
+		}
+		return 0, nil
+	}
+	if req.EfSearch != 0 {
+		if req.EfSearch < 0 || req.EfSearch > obj.cfg.MaxEfSearch {
+			return 0, fmt.Errorf("%w: must be within [1, %d]", ErrInvalidEfSearch, obj.cfg.MaxEfSearch)
+		}
+		return req.EfSearch, nil
+	}
+
+	switch strings.TrimSpace(strings.ToLower(req.Profile)) {
+	case "":
+		return obj.cfg.EfSearch, nil
+	case profileFast:
+		return min(fastEfSearch, obj.cfg.MaxEfSearch), nil
+	case profileBalanced:
+		return min(balancedEfSearch, obj.cfg.MaxEfSearch), nil
+	case profileAccurate:
+		return min(accurateEfSearch, obj.cfg.MaxEfSearch), nil
+	default:
+		return 0, ErrInvalidProfile
+	}
+}
+
+// cursorEfSearch widens ef_search for a cursor page so the HNSW candidate set
+// also covers the rows of earlier pages, which the cursor predicate discards.
+// It never drops below the page's own setting (0 meaning the pgvector
+// default); past maxEfSearch pages may come back short.
+func cursorEfSearch(efSearch, seen, limit, maxEfSearch int) int {
+	if efSearch == 0 {
+		efSearch = pgvectorEfSearch
+	}
+	return max(efSearch, min(seen+limit, maxEfSearch))
+}
+
+func (obj *Handler) hybridSearch(
+	ctx context.Context,
+	req *SearchRequest,
+	vec *pgvector.Vector,
+	opts database.SearchOptions,
+) ([]*HitResponse, error) {
+	hybrid := HybridOptions{}
+	if req.Hybrid != nil {
+		hybrid = *req.Hybrid
+	}
+	vectorWeight, textWeight := 1.0, 1.0
+	if hybrid.VectorWeight != nil {
+		vectorWeight = *hybrid.VectorWeight
+	}
+	if hybrid.TextWeight != nil {
+		textWeight = *hybrid.TextWeight
+	}
+	if vectorWeight < 0 || textWeight < 0 || vectorWeight+textWeight == 0 {
+		return nil, badRequest(ErrInvalidWeight)
+	}
+	fetch := hybrid.FetchK
+	if fetch < req.Limit {
+		fetch = req.Limit * hybridFetchFactor
+	}
+	fetch = min(fetch, maxFetch)
+
+	vectorHits, err := obj.db.Search(ctx, vec, fetch, opts)
+	if err != nil {
+		return nil, err
+	}
+	// The index settings in opts (ef_search, exact) do not apply here: the
+	// lexical query is not approximate.
+	textHits, err := obj.db.LexicalSearch(ctx, vec, req.Query, fetch, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	fused := search.FuseRRF(hybrid.RRFK, req.Limit,
+		search.RankedList{Hits: vectorHits, Weight: vectorWeight},
+		search.RankedList{Hits: textHits, Weight: textWeight},
+	)
+	responses := make([]*HitResponse, 0, len(fused))
+	for _, hit := range fused {
+		resp, err := UnmapHit(hit.SearchHit, opts.Metric, req.IncludeEmbedding)
+		if err != nil {
+			return nil, err
+		}
+		resp.Relevance = &hit.Score
+		responses = append(responses, &resp)
+	}
+	return responses, nil
+}
+
+func (obj *Handler) diversifiedSearch(
+	ctx context.Context,
+	req *SearchRequest,
+	vec *pgvector.Vector,
+	opts database.SearchOptions,
+) ([]*HitResponse, error) {
+	lambda := defaultMMRLambda
+	if req.Diversify.Lambda != nil {
+		lambda = *req.Diversify.Lambda
+	}
+	if lambda < 0 || lambda > 1 {
+		return nil, badRequest(ErrInvalidLambda)
+	}
+	fetch := req.Diversify.FetchK
+	if fetch < req.Limit {
+		fetch = req.Limit * mmrFetchFactor
+	}
+	fetch = min(fetch, maxFetch)
+
+	candidates, err := obj.fetchHits(ctx, vec, req.ClusterIDs, fetch, opts)
+	if err != nil {
+		return nil, err
+	}
+	embeddings := make([][]float32, len(candidates))
+	for idx, hit := range candidates {
+		embeddings[idx] = hit.Embedding.Slice()
+	}
+
+	order := search.MMR(vec.Slice(), embeddings, lambda, req.Limit)
+	hits := make([]*database.SearchHit, len(order))
+	for pos, idx := range order {
+		hits[pos] = candidates[idx]
+	}
+	return unmapHits(hits, opts.Metric, req.IncludeEmbedding)
+}
+
+func (obj *Handler) fetchHits(
+	ctx context.Context,
+	vec *pgvector.Vector,
+	clusterIDs []int32,
+	limit int,
+	opts database.SearchOptions,
+) ([]*database.SearchHit, error) {
+	if len(clusterIDs) > 0 {
+		return obj.db.SearchInClusters(ctx, vec, clusterIDs, limit, opts)
+	}
+	return obj.db.Search(ctx, vec, limit, opts)
+}
+
+func unmapHits(hits []*database.SearchHit, metric database.Metric, withEmbedding bool) ([]*HitResponse, error) {
+	responses := make([]*HitResponse, 0, len(hits))
+	for _, hit := range hits {
+		resp, err := UnmapHit(hit, metric, withEmbedding)
+		if err != nil {
+			return nil, err
+		}
+		responses = append(responses, &resp)
+	}
+	return responses, nil
+}
+
+func unmapDocuments(
+	docs []*search.DocumentHit,
+	metric database.Metric,
+	req *SearchRequest,
+) ([]*DocumentHitResponse, error) {
+	responses := make([]*DocumentHitResponse, 0, len(docs))
+	for _, doc := range docs {
+		best, err := UnmapHit(doc.Best, metric, req.IncludeEmbedding)
+		if err != nil {
+			return nil, err
+		}
+		resp := DocumentHitResponse{
+			DocID:         doc.DocID,
+			Distance:      doc.Distance,
+			MatchedChunks: len(doc.Chunks),
+			BestChunk:     best,
+		}
+		if similarity, ok := metric.Similarity(doc.Distance); ok {
+			resp.Similarity = &similarity
+		}
+		if req.IncludeChunks {
+			if resp.Chunks, err = unmapHits(doc.Chunks, metric, req.IncludeEmbedding); err != nil {
+				return nil, err
+			}
+		}
+		responses = append(responses, &resp)
+	}
+	return responses, nil
+}
+
+func (obj *Handler) searchBatch(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodPost {
+		obj.sendErrResponse(writer, "method not allowed", http.StatusMethodNotAllowed, nil)
+		return
+	}
+
+	var rawQueries []json.RawMessage
+	if err := json.NewDecoder(request.Body).Decode(&rawQueries); err != nil {
+		obj.sendErrResponse(writer, "bad request: "+err.Error(), http.StatusBadRequest, err)
+		return
+	}
+	if len(rawQueries) == 0 || len(rawQueries) > maxBatchQueries {
+		obj.sendErrResponse(writer, "bad request: "+ErrInvalidBatchSize.Error(), http.StatusBadRequest, nil)
+		return
+	}
+
+	results := make([]*BatchSearchResult, len(rawQueries))
+	slots := make(chan struct{}, obj.cfg.SearchWorkers)
+	var waitGroup sync.WaitGroup
+	for idx, raw := range rawQueries {
+		waitGroup.Add(1)
+		slots <- struct{}{}
+		go func() {
+			defer waitGroup.Done()
+			defer func() { <-slots }()
+			results[idx] = obj.runBatchQuery(request.Context(), raw)
+		}()
+	}
+	waitGroup.Wait()
+
+	obj.sendJSON(writer, http.StatusOK, results)
+}
+
+func (obj *Handler) runBatchQuery(ctx context.Context, raw json.RawMessage) *BatchSearchResult {
+	var req SearchRequest
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		return &BatchSearchResult{Status: http.StatusBadRequest, Error: "bad request: " + err.Error()}
+	}
+
+	result, err := obj.runSearch(ctx, &req)
+	if err != nil {
+		var reqErr *requestError
+		if errors.As(err, &reqErr) {
+			return &BatchSearchResult{Status: http.StatusBadRequest, Error: "bad request: " + err.Error()}
+		}
+		obj.logger.Error("batch query failed", zap.Error(err))
+		return &BatchSearchResult{Status: http.StatusInternalServerError, Error: "internal server error"}
+	}
+	return &BatchSearchResult{Results: result.results, NextCursor: result.nextCursor, Status: http.StatusOK}
+}
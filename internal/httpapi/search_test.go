@@ -0,0 +1,26 @@
+package httpapi
+
+import "testing"
+
+func TestCursorEfSearch(t *testing.T) {
+	tests := []struct {
+		name     string
+		efSearch int
+		seen     int
+		limit    int
+		want     int
+	}{
+		{name: "default setting is the floor", efSearch: 0, seen: 4, limit: 4, want: pgvectorEfSearch},
+		{name: "configured setting is the floor", efSearch: 100, seen: 20, limit: 10, want: 100},
+		{name: "widens past earlier pages", efSearch: 0, seen: 90, limit: 10, want: 100},
+		{name: "capped at the maximum", efSearch: 0, seen: 390, limit: 20, want: 400},
+		{name: "setting at the cap is kept", efSearch: 400, seen: 500, limit: 10, want: 400},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := cursorEfSearch(tt.efSearch, tt.seen, tt.limit, 400); got != tt.want {
+				t.Fatalf("cursorEfSearch() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,190 @@
+package httpapi
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	database "github.com/atroxxxxxx/embed-store/internal/db"
+	"go.uber.org/zap"
+)
+
+const similarSuffix = "/similar"
+
+func (obj *Handler) similarChunk(writer http.ResponseWriter, request *http.Request) {
+	id, err := parseID(strings.TrimSuffix(request.URL.Path, similarSuffix), "/chunks/")
+	if err != nil {
+		obj.sendErrResponse(writer, "not found", http.StatusNotFound, err)
+		return
+	}
+	req, err := searchRequestFromQuery(request.URL.Query())
+	if err != nil {
+		obj.sendErrResponse(writer, "bad request: "+err.Error(), http.StatusBadRequest, err)
+		return
+	}
+
+	chunk, err := obj.db.ChunkByID(request.Context(), id)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			obj.sendErrResponse(writer, "not found", http.StatusNotFound, err)
+		} else {
+			obj.sendRequestErr(writer, err)
+		}
+		return
+	}
+	req.Embedding = chunk.Embedding.Slice()
+	req.excludeIDs = []int64{id}
+
+	obj.sendSimilar(writer, request, req)
+}
+
+func (obj *Handler) similarDocument(writer http.ResponseWriter, request *http.Request) {
+	docID, err := parseID(strings.TrimSuffix(request.URL.Path, similarSuffix), "/documents/")
+	if err != nil {
+		obj.sendErrResponse(writer, "not found", http.StatusNotFound, err)
+		return
+	}
+	req, err := searchRequestFromQuery(request.URL.Query())
+	if err != nil {
+		obj.sendErrResponse(writer, "bad request: "+err.Error(), http.StatusBadRequest, err)
+		return
+	}
+
+	vec, err := obj.db.DocumentEmbedding(request.Context(), docID)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			obj.sendErrResponse(writer, "not found", http.StatusNotFound, err)
+		} else {
+			obj.sendErrResponse(writer, "internal server error", http.StatusInternalServerError, err)
+		}
+		return
+	}
+	req.Embedding = vec.Slice()
+	req.excludeDocIDs = []int64{docID}
+
+	obj.sendSimilar(writer, request, req)
+}
+
+func (obj *Handler) sendSimilar(writer http.ResponseWriter, request *http.Request, req *SearchRequest) {
+	result, err := obj.runSearch(request.Context(), req)
+	if err != nil {
+		obj.sendRequestErr(writer, err)
+		return
+	}
+	obj.sendSearchResult(writer, result)
+	obj.logger.Info("similar search", zap.String("path", request.URL.Path))
+}
+
+// searchRequestFromQuery builds a SearchRequest from GET parameters; list
+// parameters may be repeated or comma separated (except author).
+func searchRequestFromQuery(values url.Values) (*SearchRequest, error) {
+	req := &SearchRequest{
+		Metric:           values.Get("metric"),
+		GroupBy:          values.Get("group_by"),
+		Aggregate:        values.Get("aggregate"),
+		IncludeChunks:    values.Get("include_chunks") == "1",
+		IncludeEmbedding: values.Get("include_embedding") == "1",
+		IncludeDeleted:   values.Get("include_deleted") == "1",
+		Cursor:           values.Get("cursor"),
+		Profile:          values.Get("profile"),
+		Exact:            values.Get("exact") == "1",
+	}
+
+	var err error
+	if raw := values.Get("limit"); raw != "" {
+		if req.Limit, err = strconv.Atoi(raw); err != nil {
+			return nil, fmt.Errorf("limit: %w", err)
+		}
+	}
+	if raw := values.Get("ef_search"); raw != "" {
+		if req.EfSearch, err = strconv.Atoi(raw); err != nil {
+			return nil, fmt.Errorf("ef_search: %w", err)
+		}
+	}
+	if raw := values.Get("nprobe"); raw != "" {
+		if req.NProbe, err = strconv.Atoi(raw); err != nil {
+			return nil, fmt.Errorf("nprobe: %w", err)
+		}
+	}
+	if raw := values.Get("max_distance"); raw != "" {
+		maxDistance, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return nil, fmt.Errorf("max_distance: %w", err)
+		}
+		req.MaxDistance = &maxDistance
+	}
+	for _, raw := range listValues(values, "cluster_id") {
+		clusterID, err := strconv.ParseInt(raw, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("cluster_id: %w", err)
+		}
+		req.ClusterIDs = append(req.ClusterIDs, int32(clusterID))
+	}
+
+	if req.Filter, err = filterFromQuery(values); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+func filterFromQuery(values url.Values) (*SearchFilter, error) {
+	filter := &SearchFilter{
+		Types:   listValues(values, "type"),
+		Authors: values["author"],
+	}
+
+	if from, to := values.Get("time_from"), values.Get("time_to"); from != "" || to != "" {
+		filter.Time = &TimeRange{From: from, To: to}
+	}
+	if raw := values.Get("min_score"); raw != "" {
+		score, err := strconv.ParseInt(raw, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("min_score: %w", err)
+		}
+		minScore := int32(score)
+		filter.MinScore = &minScore
+	}
+	for _, raw := range listValues(values, "doc_id") {
+		docID, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("doc_id: %w", err)
+		}
+		filter.DocIDs = append(filter.DocIDs, docID)
+	}
+
+	var err error
+	if filter.Deleted, err = boolValue(values, "deleted"); err != nil {
+		return nil, err
+	}
+	if filter.Dead, err = boolValue(values, "dead"); err != nil {
+		return nil, err
+	}
+	return filter, nil
+}
+
+func listValues(values url.Values, key string) []string {
+	var out []string
+	for _, value := range values[key] {
+		for _, part := range strings.Split(value, ",") {
+			if part = strings.TrimSpace(part); part != "" {
+				out = append(out, part)
+			}
+		}
+	}
+	return out
+}
+
+func boolValue(values url.Values, key string) (*bool, error) {
+	raw := values.Get(key)
+	if raw == "" {
+		return nil, nil
+	}
+	parsed, err := strconv.ParseBool(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", key, err)
+	}
+	return &parsed, nil
+}
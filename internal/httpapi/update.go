@@ -0,0 +1,130 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	database "github.com/atroxxxxxx/embed-store/internal/db"
+	"github.com/pgvector/pgvector-go"
+	"go.uber.org/zap"
+)
+
+var ErrInvalidID = errors.New("invalid id")
+
+func parseID(path, prefix string) (int64, error) {
+	rawID, found := strings.CutPrefix(path, prefix)
+	if !found || rawID == "" {
+		return 0, ErrInvalidID
+	}
+	id, err := strconv.ParseInt(rawID, 10, 64)
+	if err != nil || id <= 0 {
+		return 0, ErrInvalidID
+	}
+	return id, nil
+}
+
+func (obj *Handler) put(writer http.ResponseWriter, request *http.Request) {
+	id, err := parseID(request.URL.Path, "/chunks/")
+	if err != nil {
+		obj.sendErrResponse(writer, "not found", http.StatusNotFound, err)
+		return
+	}
+
+	var req Request
+	dec := json.NewDecoder(request.Body)
+	dec.DisallowUnknownFields()
+	if err = dec.Decode(&req); err != nil {
+		obj.sendErrResponse(writer, "bad request", http.StatusBadRequest, err)
+		return
+	}
+	if len(req.Embedding) == 0 {
+		if req.Embedding, err = obj.embed(request.Context(), req.Text); err != nil {
+			obj.sendRequestErr(writer, err)
+			return
+		}
+	}
+	chunk, err := Map(&req)
+	if err != nil {
+		obj.sendErrResponse(writer, "bad request", http.StatusBadRequest, err)
+		return
+	}
+	obj.assignClusters(chunk)
+
+	if err = obj.db.UpdateChunk(request.Context(), id, chunk); err != nil {
+		switch {
+		case errors.Is(err, database.ErrNotFound):
+			obj.sendErrResponse(writer, "not found", http.StatusNotFound, err)
+		case errors.Is(err, database.ErrDuplicateKey):
+			obj.sendErrResponse(writer, "conflict", http.StatusConflict, err)
+		default:
+			obj.sendErrResponse(writer, "internal server error", http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	resp, err := Unmap(chunk, false)
+	if err != nil {
+		obj.sendErrResponse(writer, "internal server error", http.StatusInternalServerError, err)
+		return
+	}
+	obj.sendJSON(writer, http.StatusOK, WriteResponse{Response: resp, Status: statusUpdated})
+	obj.logger.Info("chunk replaced", zap.Int64("id", id))
+}
+
+func (obj *Handler) patch(writer http.ResponseWriter, request *http.Request) {
+	id, err := parseID(request.URL.Path, "/chunks/")
+	if err != nil {
+		obj.sendErrResponse(writer, "not found", http.StatusNotFound, err)
+		return
+	}
+
+	var req PatchRequest
+	dec := json.NewDecoder(request.Body)
+	dec.DisallowUnknownFields()
+	if err = dec.Decode(&req); err != nil {
+		obj.sendErrResponse(writer, "bad request", http.StatusBadRequest, err)
+		return
+	}
+	patch, err := MapPatch(&req)
+	if err != nil {
+		obj.sendErrResponse(writer, "bad request: "+err.Error(), http.StatusBadRequest, err)
+		return
+	}
+	// Keep the stored embedding in sync with the new text; without an
+	// embedder the request has to supply the new embedding itself.
+	if patch.Text != nil && patch.Embedding == nil {
+		embedding, err := obj.embed(request.Context(), *patch.Text)
+		if err != nil {
+			obj.sendRequestErr(writer, err)
+			return
+		}
+		vec := pgvector.NewVector(embedding)
+		patch.Embedding = &vec
+	}
+	if patch.Embedding != nil {
+		assigned := &database.Chunk{Embedding: *patch.Embedding}
+		obj.assignClusters(assigned)
+		patch.ClusterID, patch.ClusterRunID = assigned.ClusterID, assigned.ClusterRunID
+	}
+
+	chunk, err := obj.db.PatchChunk(request.Context(), id, patch)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			obj.sendErrResponse(writer, "not found", http.StatusNotFound, err)
+		} else {
+			obj.sendErrResponse(writer, "internal server error", http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	resp, err := Unmap(chunk, false)
+	if err != nil {
+		obj.sendErrResponse(writer, "internal server error", http.StatusInternalServerError, err)
+		return
+	}
+	obj.sendJSON(writer, http.StatusOK, WriteResponse{Response: resp, Status: statusUpdated})
+	obj.logger.Info("chunk patched", zap.Int64("id", id))
+}
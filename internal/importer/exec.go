@@ -12,6 +12,7 @@ import (
 func ExecImporter(
 	ctx context.Context,
 	db *database.Database,
+	assigner Assigner,
 	cfg runcfg.RunConfig,
 	log *zap.Logger,
 	tickTime time.Duration,
@@ -49,7 +50,7 @@ func ExecImporter(
 	}()
 
 	start := time.Now()
-	err := Run(importCtx, db, cfg.ImportCfg, stats)
+	err := Run(importCtx, db, assigner, cfg.ImportCfg, stats)
 	duration := time.Since(start)
 
 	if err != nil {
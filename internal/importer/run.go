@@ -12,7 +12,8 @@ var (
 	ErrInvalidArgs = errors.New("invalid function args")
 )
 
-func Run(ctx context.Context, repo Repo, config Config, stats *Stats) error {
+// Run imports the CSV file; assigner may be nil.
+func Run(ctx context.Context, repo Repo, assigner Assigner, config Config, stats *Stats) error {
 	if repo == nil || stats == nil || config.Workers <= 0 || config.FilePath == "" {
 		return ErrInvalidArgs
 	}
@@ -24,7 +25,7 @@ func Run(ctx context.Context, repo Repo, config Config, stats *Stats) error {
 	for range config.Workers {
 		go func() {
 			defer waitGroup.Done()
-			runWorker(ctx, repo, jobs, stats, config.BatchSize)
+			runWorker(ctx, repo, assigner, jobs, stats, config.BatchSize)
 		}()
 	}
 
@@ -40,7 +41,7 @@ func Run(ctx context.Context, repo Repo, config Config, stats *Stats) error {
 	return nil
 }
 
-func runWorker(ctx context.Context, repo Repo, jobs <-chan *db.Chunk, stats *Stats, batchSize int) {
+func runWorker(ctx context.Context, repo Repo, assigner Assigner, jobs <-chan *db.Chunk, stats *Stats, batchSize int) {
 	if batchSize == 0 {
 		batchSize = 1
 	}
@@ -50,6 +51,9 @@ func runWorker(ctx context.Context, repo Repo, jobs <-chan *db.Chunk, stats *Sta
 			return
 		}
 
+		if assigner != nil {
+			assigner.AssignChunks(batch...)
+		}
 		inserted, err := repo.InsertBatch(ctx, batch)
 		if err != nil {
 			stats.Failed.Add(int64(len(batch)))
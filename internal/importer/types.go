@@ -12,6 +12,11 @@ type Repo interface {
 	InsertBatch(ctx context.Context, batch []*db.Chunk) (int64, error)
 }
 
+// Assigner sets cluster ids on chunks before they are inserted.
+type Assigner interface {
+	AssignChunks(chunks ...*db.Chunk)
+}
+
 type Config struct {
 	FilePath  string
 	Workers   int
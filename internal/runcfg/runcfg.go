@@ -10,13 +10,27 @@ import (
 	"github.com/atroxxxxxx/embed-store/internal/logger"
 )
 
-const DefaultHTTP = ":8080"
+const (
+	DefaultHTTP = ":8080"
+	// pgvectorMaxEfSearch is the largest hnsw.ef_search pgvector accepts.
+	pgvectorMaxEfSearch = 1000
+)
 
 var ErrDSNEmpty = errors.New("incomplete db config")
 
 type RunConfig struct {
-	DSN       string
-	HTTPAddr  string
+	DSN      string
+	HTTPAddr string
+	HTTPCfg  struct {
+		BatchSize     int
+		SearchWorkers int
+		EfSearch      int
+		MaxEfSearch   int
+		AdminToken    string
+	}
+	// Command holds positional arguments; when set the binary runs the
+	// administrative command instead of the server.
+	Command   []string
 	LogLevel  string
 	RunImport bool
 	ImportCfg struct {
@@ -25,6 +39,13 @@ type RunConfig struct {
 		BatchSize int
 		Limit     int
 	}
+	EmbedderCfg struct {
+		URL     string
+		Format  string
+		Model   string
+		APIKey  string
+		Timeout int
+	}
 	RunCluster bool
 	ClusterCfg struct {
 		Clusters  int
@@ -32,6 +53,17 @@ type RunConfig struct {
 		Workers   int
 		Limit     int
 		BatchSize int
+		// BackfillInterval is in seconds; 0 disables the cluster id backfill.
+		BackfillInterval int
+		Seed             int
+		Tolerance        float64
+		Mode             string
+		Epochs           int
+		Metric           string
+		SweepMin         int
+		SweepMax         int
+		SweepStep        int
+		SilhouetteSample int
 	}
 }
 
@@ -55,12 +87,16 @@ func Parse() (RunConfig, error) {
 	}
 
 	return RunConfig{
-			DSN:        temp.DSN,
-			HTTPAddr:   *addr,
-			LogLevel:   *logLevel,
-			RunImport:  *runImport,
-			ImportCfg:  temp.ImportCfg,
-			RunCluster: *runCluster,
+			DSN:         temp.DSN,
+			HTTPAddr:    *addr,
+			HTTPCfg:     temp.HTTPCfg,
+			Command:     flag.Args(),
+			LogLevel:    *logLevel,
+			RunImport:   *runImport,
+			ImportCfg:   temp.ImportCfg,
+			EmbedderCfg: temp.EmbedderCfg,
+			RunCluster:  *runCluster,
+			ClusterCfg:  temp.ClusterCfg,
 		},
 		nil
 }
@@ -73,6 +109,16 @@ func parseEnv() (RunConfig, error) {
 		cfg.HTTPAddr = DefaultHTTP
 	}
 
+	cfg.HTTPCfg.BatchSize = getEnvCount("HTTP_BATCH_SIZE", 500)
+	cfg.HTTPCfg.SearchWorkers = getEnvCount("SEARCH_BATCH_WORKERS", 8)
+	cfg.HTTPCfg.EfSearch = getEnvCount("SEARCH_EF_SEARCH", 0)
+	cfg.HTTPCfg.MaxEfSearch = getEnvCount("SEARCH_EF_SEARCH_MAX", 400)
+	if cfg.HTTPCfg.MaxEfSearch > pgvectorMaxEfSearch {
+		return cfg, fmt.Errorf("invalid SEARCH_EF_SEARCH_MAX: %d is above the pgvector limit of %d",
+			cfg.HTTPCfg.MaxEfSearch, pgvectorMaxEfSearch)
+	}
+	cfg.HTTPCfg.AdminToken = os.Getenv("ADMIN_TOKEN")
+
 	cfg.LogLevel = os.Getenv("LOG_LEVEL")
 	if cfg.LogLevel == "" {
 		cfg.LogLevel = logger.Info
@@ -94,6 +140,12 @@ func parseEnv() (RunConfig, error) {
 		cfg.ImportCfg.Limit = getEnvCount("IMPORT_LIMIT", 0)
 	}
 
+	cfg.EmbedderCfg.URL = os.Getenv("EMBEDDER_URL")
+	cfg.EmbedderCfg.Format = os.Getenv("EMBEDDER_FORMAT")
+	cfg.EmbedderCfg.Model = os.Getenv("EMBEDDER_MODEL")
+	cfg.EmbedderCfg.APIKey = os.Getenv("EMBEDDER_API_KEY")
+	cfg.EmbedderCfg.Timeout = getEnvCount("EMBEDDER_TIMEOUT", 10)
+
 	if envFlag := os.Getenv("RUN_CLUSTER"); envFlag != "" {
 		boolFlag, err := strconv.ParseBool(envFlag)
 		if err != nil {
@@ -107,8 +159,20 @@ func parseEnv() (RunConfig, error) {
 		cfg.ClusterCfg.Iters = getEnvCount("CLUSTER_ITERS", 10)
 		cfg.ClusterCfg.Workers = getEnvCount("CLUSTER_WORKERS", 6)
 		cfg.ClusterCfg.Limit = getEnvCount("CLUSTER_LIMIT", 20000)
-		cfg.ClusterCfg.BatchSize = getEnvCount("CLUSTER_BATCH_SIZE", 1000)
+		cfg.ClusterCfg.Seed = getEnvCount("CLUSTER_SEED", 0)
+		cfg.ClusterCfg.Tolerance = getEnvFloat("CLUSTER_TOLERANCE", 1e-4)
+		cfg.ClusterCfg.Mode = os.Getenv("CLUSTER_MODE")
+		cfg.ClusterCfg.Epochs = getEnvCount("CLUSTER_EPOCHS", 3)
+		cfg.ClusterCfg.Metric = os.Getenv("CLUSTER_METRIC")
+		cfg.ClusterCfg.SweepMin = getEnvCount("CLUSTER_SWEEP_MIN", 8)
+		cfg.ClusterCfg.SweepMax = getEnvCount("CLUSTER_SWEEP_MAX", 128)
+		cfg.ClusterCfg.SweepStep = getEnvCount("CLUSTER_SWEEP_STEP", 8)
+		cfg.ClusterCfg.SilhouetteSample = getEnvCount("CLUSTER_SILHOUETTE_SAMPLE", 1000)
 	}
+	// The batch size also pages the cluster id backfill, which runs without
+	// RUN_CLUSTER.
+	cfg.ClusterCfg.BatchSize = getEnvCount("CLUSTER_BATCH_SIZE", 1000)
+	cfg.ClusterCfg.BackfillInterval = getEnvCount("CLUSTER_BACKFILL_INTERVAL", 60)
 
 	host := os.Getenv("DB_HOST")
 	port := os.Getenv("DB_PORT")
@@ -144,3 +208,15 @@ func getEnvCount(key string, def int) int {
 	}
 	return int(count)
 }
+
+func getEnvFloat(key string, def float64) float64 {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return def
+	}
+	return parsed
+}
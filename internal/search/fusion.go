@@ -0,0 +1,59 @@
+package search
+
+import (
+	"slices"
+
+	"github.com/atroxxxxxx/embed-store/internal/db"
+)
+
+const DefaultRRFK = 60
+
+type RankedList struct {
+	Hits   []*db.SearchHit
+	Weight float64
+}
+
+type FusedHit struct {
+	*db.SearchHit
+	Score float64
+}
+
+// FuseRRF merges ranked lists with weighted reciprocal rank fusion:
+// score(d) = sum(weight / (k + rank(d))), where rank starts at 1.
+func FuseRRF(k float64, limit int, lists ...RankedList) []*FusedHit {
+	if k <= 0 {
+		k = DefaultRRFK
+	}
+
+	fused := make([]*FusedHit, 0, limit)
+	byID := make(map[int64]*FusedHit)
+	for _, list := range lists {
+		for rank, hit := range list.Hits {
+			if hit == nil {
+				continue
+			}
+			entry, ok := byID[hit.ID]
+			if !ok {
+				entry = &FusedHit{SearchHit: hit}
+				byID[hit.ID] = entry
+				fused = append(fused, entry)
+			}
+			entry.Score += list.Weight / (k + float64(rank+1))
+		}
+	}
+
+	slices.SortStableFunc(fused, func(left, right *FusedHit) int {
+		switch {
+		case left.Score > right.Score:
+			return -1
+		case left.Score < right.Score:
+			return 1
+		default:
+			return 0
+		}
+	})
+	if len(fused) > limit {
+		fused = fused[:limit]
+	}
+	return fused
+}
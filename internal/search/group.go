@@ -0,0 +1,87 @@
+package search
+
+import (
+	"errors"
+	"slices"
+	"strings"
+
+	"github.com/atroxxxxxx/embed-store/internal/db"
+)
+
+// Aggregate names are in terms of similarity: "max" keeps the best (closest)
+// chunk of a document, "mean" averages the distance over its matched chunks.
+type Aggregate string
+
+const (
+	AggregateMax  Aggregate = "max"
+	AggregateMean Aggregate = "mean"
+)
+
+var ErrUnknownAggregate = errors.New("unknown aggregate")
+
+type DocumentHit struct {
+	DocID    int64
+	Distance float64
+	Best     *db.SearchHit
+	Chunks   []*db.SearchHit
+}
+
+func ParseAggregate(value string) (Aggregate, error) {
+	switch Aggregate(strings.TrimSpace(strings.ToLower(value))) {
+	case "", AggregateMax:
+		return AggregateMax, nil
+	case AggregateMean:
+		return AggregateMean, nil
+	default:
+		return "", ErrUnknownAggregate
+	}
+}
+
+// GroupByDoc collapses hits ordered by ascending distance into documents and
+// returns the top limit of them ranked by the aggregate distance.
+func GroupByDoc(hits []*db.SearchHit, aggregate Aggregate, limit int) []*DocumentHit {
+	docs := make([]*DocumentHit, 0, limit)
+	byDoc := make(map[int64]*DocumentHit, len(hits))
+	for _, hit := range hits {
+		if hit == nil {
+			continue
+		}
+		doc, ok := byDoc[hit.DocID]
+		if !ok {
+			doc = &DocumentHit{DocID: hit.DocID, Best: hit}
+			byDoc[hit.DocID] = doc
+			docs = append(docs, doc)
+		}
+		if hit.Distance < doc.Best.Distance {
+			doc.Best = hit
+		}
+		doc.Chunks = append(doc.Chunks, hit)
+	}
+
+	for _, doc := range docs {
+		if aggregate != AggregateMean {
+			doc.Distance = doc.Best.Distance
+			continue
+		}
+		var sum float64
+		for _, chunk := range doc.Chunks {
+			sum += chunk.Distance
+		}
+		doc.Distance = sum / float64(len(doc.Chunks))
+	}
+
+	slices.SortStableFunc(docs, func(left, right *DocumentHit) int {
+		switch {
+		case left.Distance < right.Distance:
+			return -1
+		case left.Distance > right.Distance:
+			return 1
+		default:
+			return 0
+		}
+	})
+	if len(docs) > limit {
+		docs = docs[:limit]
+	}
+	return docs
+}
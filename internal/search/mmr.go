@@ -0,0 +1,60 @@
+package search
+
+import "math"
+
+// MMR greedily picks up to k candidates maximizing
+// lambda*sim(query, c) - (1-lambda)*max(sim(c, selected)) with cosine
+// similarity, and returns their indices in pick order.
+func MMR(query []float32, candidates [][]float32, lambda float64, k int) []int {
+	k = min(k, len(candidates))
+	if k <= 0 {
+		return nil
+	}
+
+	relevance := make([]float64, len(candidates))
+	redundancy := make([]float64, len(candidates))
+	picked := make([]bool, len(candidates))
+	for idx, candidate := range candidates {
+		relevance[idx] = cosineSimilarity(query, candidate)
+		redundancy[idx] = math.Inf(-1)
+	}
+
+	order := make([]int, 0, k)
+	for len(order) < k {
+		best, bestScore := -1, math.Inf(-1)
+		for idx := range candidates {
+			if picked[idx] {
+				continue
+			}
+			score := lambda * relevance[idx]
+			if len(order) > 0 {
+				score -= (1 - lambda) * redundancy[idx]
+			}
+			if score > bestScore {
+				best, bestScore = idx, score
+			}
+		}
+
+		picked[best] = true
+		order = append(order, best)
+		for idx, candidate := range candidates {
+			if !picked[idx] {
+				redundancy[idx] = max(redundancy[idx], cosineSimilarity(candidates[best], candidate))
+			}
+		}
+	}
+	return order
+}
+
+func cosineSimilarity(left, right []float32) float64 {
+	var dot, leftNorm, rightNorm float64
+	for i := range min(len(left), len(right)) {
+		dot += float64(left[i]) * float64(right[i])
+		leftNorm += float64(left[i]) * float64(left[i])
+		rightNorm += float64(right[i]) * float64(right[i])
+	}
+	if leftNorm == 0 || rightNorm == 0 {
+		return 0
+	}
+	return dot / math.Sqrt(leftNorm*rightNorm)
+}
@@ -0,0 +1,42 @@
+package search
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestMMR(t *testing.T) {
+	query := []float32{1, 0, 0}
+	candidates := [][]float32{
+		{1, 0.1, 0},     // most relevant
+		{1, 0.1, 0.001}, // near-duplicate of the first
+		{0.8, 0, 0.6},   // less relevant but distinct
+	}
+
+	tests := []struct {
+		name   string
+		lambda float64
+		want   []int
+	}{
+		{name: "low lambda pushes the duplicate down", lambda: 0.3, want: []int{0, 2, 1}},
+		{name: "lambda 1 keeps relevance order", lambda: 1, want: []int{0, 1, 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := MMR(query, candidates, tt.lambda, len(candidates))
+			if !slices.Equal(got, tt.want) {
+				t.Fatalf("MMR() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMMRLimit(t *testing.T) {
+	candidates := [][]float32{{1, 0}, {0, 1}}
+	if got := MMR([]float32{1, 0}, candidates, 0.5, 5); len(got) != 2 {
+		t.Fatalf("MMR() returned %d indices, want 2", len(got))
+	}
+	if got := MMR([]float32{1, 0}, candidates, 0.5, 0); got != nil {
+		t.Fatalf("MMR() with k=0 = %v, want nil", got)
+	}
+}